@@ -0,0 +1,158 @@
+package update
+
+import (
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// AutoAssetFilter can be passed as assetfilter to select the asset
+// for the running platform instead of matching a regex, see RankAssets.
+// An empty assetfilter matches all assets.
+const AutoAssetFilter = "auto"
+
+// Platform describes the target an asset is selected for.
+type Platform struct {
+	GOOS   string
+	GOARCH string
+	// Libc is "musl" or "gnu" on linux, empty if unknown.
+	Libc string
+}
+
+// CurrentPlatform returns the platform of the running application.
+func CurrentPlatform() Platform {
+	p := Platform{
+		GOOS:   runtime.GOOS,
+		GOARCH: runtime.GOARCH,
+	}
+	if p.GOOS == "linux" {
+		p.Libc = "gnu"
+		if m, _ := filepath.Glob("/lib/ld-musl-*"); len(m) > 0 {
+			p.Libc = "musl"
+		}
+	}
+	return p
+}
+
+// AssetCandidate is an asset name with its score for a platform, a higher score is a better match.
+type AssetCandidate struct {
+	Name  string
+	Score int
+}
+
+type alias struct {
+	value   string
+	pattern *regexp.Regexp
+}
+
+func aliases(value string, names ...string) alias {
+	return alias{
+		value:   value,
+		pattern: regexp.MustCompile(`(^|[^a-z0-9])(` + strings.Join(names, "|") + `)([^a-z0-9]|$)`),
+	}
+}
+
+// The order matters, more specific names must come first, e.g. x86_64 before x86.
+var (
+	osAliases = []alias{
+		aliases("windows", "windows", "win64", "win32", "win"),
+		aliases("darwin", "darwin", "macos", "osx", "mac", "apple"),
+		aliases("linux", "linux"),
+		aliases("freebsd", "freebsd"),
+		aliases("openbsd", "openbsd"),
+		aliases("netbsd", "netbsd"),
+	}
+	archAliases = []alias{
+		aliases("amd64", "amd64", "x86_64", "x86-64", "x64", "64bit"),
+		aliases("arm64", "arm64", "aarch64", "armv8"),
+		aliases("arm", "armv7l?", "armv6l?", "armhf", "armel", "arm"),
+		aliases("386", "386", "i386", "i686", "x86", "32bit"),
+		aliases("universal", "universal", "all"),
+	}
+	libcAliases = []alias{
+		aliases("musl", "musl"),
+		aliases("gnu", "gnu", "glibc"),
+	}
+	armVariants = regexp.MustCompile(`armv7|armhf`)
+
+	// Suffixes of assets that can't be installed by SelfUpdateAndRestart.
+	ignoredSuffixes = []string{
+		".sha256", ".sha512", ".sha1", ".md5", ".sig", ".asc", ".pem", ".sbom", ".json", ".txt",
+		".patch", ".deb", ".rpm", ".apk", ".msi", ".pkg", ".dmg",
+		".tar.gz", ".tgz", ".tar.xz", ".tar.bz2", ".7z",
+	}
+)
+
+func detect(name string, list []alias) string {
+	for _, a := range list {
+		if a.pattern.MatchString(name) {
+			return a.value
+		}
+	}
+	return ""
+}
+
+// RankAssets returns the assets which can be installed on platform, the best match first.
+// It recognises common naming conventions like linux/Linux, amd64/x86_64/x64, arm64/aarch64,
+// armv7 and musl/gnu. Assets for another OS or architecture, checksums, signatures and
+// archive formats other than zip are dropped.
+func RankAssets(names []string, platform Platform) []AssetCandidate {
+	candidates := make([]AssetCandidate, 0)
+	for _, name := range names {
+		if score, ok := scoreAsset(name, platform); ok {
+			candidates = append(candidates, AssetCandidate{Name: name, Score: score})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
+
+func scoreAsset(name string, platform Platform) (int, bool) {
+	lower := strings.ToLower(name)
+	for _, s := range ignoredSuffixes {
+		if strings.HasSuffix(lower, s) {
+			return 0, false
+		}
+	}
+
+	score := 0
+
+	switch goos := detect(lower, osAliases); {
+	case goos == platform.GOOS:
+		score += 4
+	case goos == "" && strings.HasSuffix(lower, ".exe") && platform.GOOS == "windows":
+		score += 2
+	case goos == "" && !strings.HasSuffix(lower, ".exe"):
+	default:
+		return 0, false
+	}
+
+	switch arch := detect(lower, archAliases); {
+	case arch == platform.GOARCH:
+		score += 4
+		if arch == "arm" && armVariants.MatchString(lower) {
+			score++
+		}
+	case arch == "universal" && platform.GOOS == "darwin":
+		score += 3
+	case arch == "":
+	default:
+		return 0, false
+	}
+
+	if platform.Libc != "" {
+		switch libc := detect(lower, libcAliases); libc {
+		case platform.Libc:
+			score += 2
+		case "":
+			score++
+		}
+	}
+
+	return score, true
+}
@@ -0,0 +1,108 @@
+package update
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/dhcgn/gh-update/types"
+)
+
+var releaseAssetNames = []string{
+	"checksums.txt",
+	"myapp_1.3.0_Linux_x86_64.zip",
+	"myapp_1.3.0_Linux_arm64.zip",
+	"myapp_1.3.0_Linux_armv6.zip",
+	"myapp_1.3.0_Linux_armv7.zip",
+	"myapp_1.3.0_Linux_i386.zip",
+	"myapp-x86_64-unknown-linux-musl",
+	"myapp-aarch64-unknown-linux-gnu",
+	"myapp_1.3.0_Darwin_universal.zip",
+	"myapp_1.3.0_Windows_x64.exe",
+	"myapp_1.3.0_Windows_x64.exe.sha256",
+	"myapp_1.3.0_Windows_arm64.exe",
+	"myapp_1.3.0_linux_amd64.tar.gz",
+}
+
+func TestRankAssets(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform Platform
+		want     string
+		wantN    int
+	}{
+		{name: "linux amd64 gnu", platform: Platform{GOOS: "linux", GOARCH: "amd64", Libc: "gnu"}, want: "myapp_1.3.0_Linux_x86_64.zip", wantN: 2},
+		{name: "linux amd64 musl", platform: Platform{GOOS: "linux", GOARCH: "amd64", Libc: "musl"}, want: "myapp-x86_64-unknown-linux-musl", wantN: 2},
+		{name: "linux arm64 gnu", platform: Platform{GOOS: "linux", GOARCH: "arm64", Libc: "gnu"}, want: "myapp-aarch64-unknown-linux-gnu", wantN: 2},
+		{name: "linux arm", platform: Platform{GOOS: "linux", GOARCH: "arm"}, want: "myapp_1.3.0_Linux_armv7.zip", wantN: 2},
+		{name: "linux 386", platform: Platform{GOOS: "linux", GOARCH: "386"}, want: "myapp_1.3.0_Linux_i386.zip", wantN: 1},
+		{name: "darwin arm64", platform: Platform{GOOS: "darwin", GOARCH: "arm64"}, want: "myapp_1.3.0_Darwin_universal.zip", wantN: 1},
+		{name: "windows amd64", platform: Platform{GOOS: "windows", GOARCH: "amd64"}, want: "myapp_1.3.0_Windows_x64.exe", wantN: 1},
+		{name: "windows arm64", platform: Platform{GOOS: "windows", GOARCH: "arm64"}, want: "myapp_1.3.0_Windows_arm64.exe", wantN: 1},
+		{name: "unsupported", platform: Platform{GOOS: "plan9", GOARCH: "amd64"}, wantN: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RankAssets(releaseAssetNames, tt.platform)
+			if len(got) != tt.wantN {
+				t.Fatalf("RankAssets() = %v, want %d candidates", got, tt.wantN)
+			}
+			if len(got) > 0 && got[0].Name != tt.want {
+				t.Errorf("RankAssets() best = %v, want %v", got[0].Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectPlatformAssets(t *testing.T) {
+	assets := []types.Assets{
+		{Name: "myapp-windows-amd64.zip"},
+		{Name: "myapp-windows-x86_64.exe"},
+		{Name: "myapp-linux-amd64.zip"},
+	}
+
+	got := selectPlatformAssets(assets, Platform{GOOS: "linux", GOARCH: "amd64"})
+	if len(got) != 1 || got[0].Name != "myapp-linux-amd64.zip" {
+		t.Errorf("selectPlatformAssets() = %v", got)
+	}
+
+	got = selectPlatformAssets(assets, Platform{GOOS: "windows", GOARCH: "amd64"})
+	if len(got) != 2 {
+		t.Errorf("selectPlatformAssets() = %v, want both windows assets as ambiguous", got)
+	}
+}
+
+func TestEmptyAssetFilterMatchesAll(t *testing.T) {
+	other := "darwin"
+	if runtime.GOOS == other {
+		other = "linux"
+	}
+	lone := []types.Assets{{Name: "myapp-" + other + "-amd64.zip", BrowserDownloadURL: "https://myapp"}}
+	tests := []struct {
+		name    string
+		filter  string
+		wantErr bool
+	}{
+		{name: "empty filter", filter: ""},
+		{name: "auto filter", filter: AutoAssetFilter, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := New(
+				WithRepository("owner/repo"),
+				WithVersion("v0.0.2"),
+				WithAssetFilter(tt.filter),
+				WithSource(&FailingWebOperationsMock{assets: lone}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			latest, err := u.GetLatestVersion()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetLatestVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && latest.Name != lone[0].Name {
+				t.Errorf("GetLatestVersion() asset = %v, want %v", latest.Name, lone[0].Name)
+			}
+		})
+	}
+}
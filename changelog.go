@@ -0,0 +1,83 @@
+package update
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReleaseNotes are the notes of one release.
+type ReleaseNotes struct {
+	Version     string
+	Title       string
+	Notes       string
+	PublishedAt time.Time
+	HTMLURL     string
+}
+
+// Changelog returns the notes of every release after the current version up to and including target,
+// see the func Changelog.
+func (u *Updater) Changelog(target string) ([]ReleaseNotes, error) {
+	// https://api.github.com/repos/dhcgn/workplace-sync/releases?per_page=100
+	apiUrl, err := url.JoinPath("https://api.github.com/repos/", u.repo, "releases")
+	if err != nil {
+		return nil, err
+	}
+	apiUrl += "?per_page=100"
+
+	notes := make([]ReleaseNotes, 0)
+	for page := apiUrl; page != ""; {
+		releases, next, err := u.webop.GetGithubReleases(page)
+		if err != nil {
+			return nil, newDownloadError(page, err)
+		}
+		u.log.Debug("releases page", "url", page, "releases", len(releases))
+
+		for _, r := range releases {
+			if CompareVersions(r.TagName, u.version) <= 0 {
+				continue
+			}
+			if r.Draft || r.Prerelease || (target != "" && CompareVersions(r.TagName, target) > 0) {
+				continue
+			}
+			notes = append(notes, ReleaseNotes{
+				Version:     r.TagName,
+				Title:       r.Name,
+				Notes:       r.Body,
+				PublishedAt: r.PublishedAt,
+				HTMLURL:     r.HTMLURL,
+			})
+		}
+
+		// Releases are listed by creation date, not by version, so a backport
+		// may be followed by newer versions and all pages are read.
+		page = next
+	}
+
+	sort.SliceStable(notes, func(i, j int) bool {
+		return CompareVersions(notes[i].Version, notes[j].Version) < 0
+	})
+	return notes, nil
+}
+
+// ChangelogMarkdown concatenates notes into one markdown changelog, the newest release first.
+func ChangelogMarkdown(notes []ReleaseNotes) string {
+	var b strings.Builder
+	for i := len(notes) - 1; i >= 0; i-- {
+		n := notes[i]
+		title := n.Version
+		if n.Title != "" && n.Title != n.Version {
+			title = fmt.Sprintf("%s - %s", n.Version, n.Title)
+		}
+		fmt.Fprintf(&b, "## %s\n\n", title)
+		if !n.PublishedAt.IsZero() {
+			fmt.Fprintf(&b, "Released %s\n\n", n.PublishedAt.Format("2006-01-02"))
+		}
+		if body := strings.TrimSpace(n.Notes); body != "" {
+			fmt.Fprintf(&b, "%s\n\n", body)
+		}
+	}
+	return strings.TrimSpace(b.String()) + "\n"
+}
@@ -0,0 +1,94 @@
+package update
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dhcgn/gh-update/types"
+)
+
+type ReleasesWebOperationsMock struct {
+	WebOperationsMock
+	pages    map[string][]types.GithubReleaseResult
+	next     map[string]string
+	requests []string
+}
+
+// GetGithubReleases implements internal.WebOperations
+func (m *ReleasesWebOperationsMock) GetGithubReleases(url string) ([]types.GithubReleaseResult, string, error) {
+	m.requests = append(m.requests, url)
+	return m.pages[url], m.next[url], nil
+}
+
+func TestChangelog(t *testing.T) {
+	first := "https://api.github.com/repos/owner/repo/releases?per_page=100"
+	second := "https://api.github.com/repos/owner/repo/releases?per_page=100&page=2"
+	third := "https://api.github.com/repos/owner/repo/releases?per_page=100&page=3"
+	fourth := "https://api.github.com/repos/owner/repo/releases?per_page=100&page=4"
+
+	mock := &ReleasesWebOperationsMock{
+		pages: map[string][]types.GithubReleaseResult{
+			first: {
+				{TagName: "v1.8.0-rc.1", Prerelease: true},
+				{TagName: "v1.7.0", Body: "seven"},
+				{TagName: "v1.6.0", Body: "six"},
+				{TagName: "v1.5.0", Body: "five", Draft: true},
+			},
+			// backports published after v1.4.0
+			second: {
+				{TagName: "v1.1.1", Body: "backport"},
+				{TagName: "v1.0.9", Body: "backport"},
+			},
+			third: {
+				{TagName: "v1.4.0", Body: "four"},
+				{TagName: "v1.3.0", Body: "three"},
+			},
+			fourth: {
+				{TagName: "v1.2.0", Body: "two"},
+				{TagName: "v1.1.0", Body: "one"},
+			},
+		},
+		next: map[string]string{first: second, second: third, third: fourth},
+	}
+	webop = mock
+
+	tests := []struct {
+		name   string
+		target string
+		want   []string
+	}{
+		{name: "up to latest", target: "v1.7.0", want: []string{"v1.3.0", "v1.4.0", "v1.6.0", "v1.7.0"}},
+		{name: "up to target", target: "v1.4.0", want: []string{"v1.3.0", "v1.4.0"}},
+		{name: "all newer", target: "", want: []string{"v1.3.0", "v1.4.0", "v1.6.0", "v1.7.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.requests = nil
+			got, err := Changelog("owner/repo", "v1.2.0", tt.target)
+			if err != nil {
+				t.Fatalf("Changelog() error = %v", err)
+			}
+			versions := make([]string, 0)
+			for _, n := range got {
+				versions = append(versions, n.Version)
+			}
+			if !reflect.DeepEqual(versions, tt.want) {
+				t.Errorf("Changelog() = %v, want %v", versions, tt.want)
+			}
+			if len(mock.requests) != 4 {
+				t.Errorf("Changelog() requested %v, want 4 pages", mock.requests)
+			}
+		})
+	}
+}
+
+func TestChangelogMarkdown(t *testing.T) {
+	notes := []ReleaseNotes{
+		{Version: "v1.3.0", Notes: "- fix\n"},
+		{Version: "v1.4.0", Title: "Big release", Notes: "- feature"},
+	}
+	want := "## v1.4.0 - Big release\n\n- feature\n\n## v1.3.0\n\n- fix\n"
+	if got := ChangelogMarkdown(notes); got != want {
+		t.Errorf("ChangelogMarkdown() = %q, want %q", got, want)
+	}
+}
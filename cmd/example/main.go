@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -35,16 +36,27 @@ func main() {
 
 	flag.Parse()
 
+	opts := []update.Option{
+		update.WithRepository("dhcgn/gh-update"),
+		update.WithVersion(Version),
+		update.WithAssetFilter("^update_.*exe$"),
+	}
 	if *updateFileFlag != "" {
 		fmt.Println("Update file:", *updateFileFlag)
-		update.SetTestUpdateAssetPath(*updateFileFlag)
+		opts = append(opts, update.WithTestUpdateAssetPath(*updateFileFlag))
+	}
+
+	updater, err := update.New(opts...)
+	if err != nil {
+		fmt.Println("ERROR:", err)
+		return
 	}
 
 	if *updateFlag {
 		fmt.Println("Checking for updates ... ")
-		err := update.SelfUpdateWithLatestAndRestart("dhcgn/gh-update", Version, "^update_.*exe$", os.Args[0])
+		err := updater.SelfUpdateWithLatestAndRestart(os.Args[0])
 
-		if err != nil && err == update.ErrorNoNewVersionFound {
+		if errors.Is(err, update.ErrorNoNewVersionFound) {
 			fmt.Println("No new version found")
 		} else if err != nil {
 			fmt.Println("ERROR Update:", err)
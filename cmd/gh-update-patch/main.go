@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dhcgn/gh-update/delta"
+)
+
+var (
+	oldFlag    = flag.String("old", "", "Path to the executable of the previous release")
+	newFlag    = flag.String("new", "", "Path to the executable of the new release")
+	outFlag    = flag.String("out", "", "Path of the patch file, defaults to <prefix>_<from>_to_<to>.patch")
+	prefixFlag = flag.String("prefix", "", "Name prefix of the patch asset, e.g. myapp")
+	fromFlag   = flag.String("from", "", "Version of the previous release, e.g. v1.2.0")
+	toFlag     = flag.String("to", "", "Version of the new release, e.g. v1.3.0")
+)
+
+func main() {
+	flag.Parse()
+
+	if *oldFlag == "" || *newFlag == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	out := *outFlag
+	if out == "" {
+		if *prefixFlag == "" || *fromFlag == "" || *toFlag == "" {
+			fmt.Fprintln(os.Stderr, "ERROR: either -out or -prefix, -from and -to must be set")
+			os.Exit(2)
+		}
+		out = delta.Name(*prefixFlag, *fromFlag, *toFlag)
+	}
+
+	oldData, err := os.ReadFile(*oldFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+	newData, err := os.ReadFile(*newFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+
+	patch, err := delta.Diff(oldData, newData)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+
+	// Verify the patch before it gets published
+	if _, err := delta.Apply(oldData, patch); err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR: patch verification failed:", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(out, patch, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Created %s (%d bytes, %d bytes new executable)\n", out, len(patch), len(newData))
+}
@@ -0,0 +1,255 @@
+// Command gh-update checks for and applies updates of any binary from its GitHub releases.
+//
+//	gh-update check    -repo owner/repo -binary /usr/local/bin/myapp [-version v1.2.0] [-filter regex] [-min-age 24h] [-json]
+//	gh-update apply    -repo owner/repo -binary /usr/local/bin/myapp [-version v1.2.0] [-filter regex] [-min-age 24h] [-json]
+//	gh-update rollback -binary /usr/local/bin/myapp [-json]
+//
+// If -version is not set, the version is read from the Go build info of the binary.
+//
+// Exit codes:
+//
+//	0  success, or no update available
+//	1  error
+//	2  invalid usage
+//	3  no matching asset in the release
+//	4  download failed
+//	5  replacing the binary failed
+//	6  another update of the binary is in progress
+//	10 check: an update is available
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	update "github.com/dhcgn/gh-update"
+)
+
+const (
+	exitOK              = 0
+	exitError           = 1
+	exitUsage           = 2
+	exitAssetSelection  = 3
+	exitDownload        = 4
+	exitSwap            = 5
+	exitInProgress      = 6
+	exitUpdateAvailable = 10
+)
+
+// result is printed as JSON with -json.
+type result struct {
+	Command         string `json:"command"`
+	Binary          string `json:"binary"`
+	Repository      string `json:"repository,omitempty"`
+	CurrentVersion  string `json:"current_version,omitempty"`
+	LatestVersion   string `json:"latest_version,omitempty"`
+	UpdateAvailable bool   `json:"update_available"`
+	Urgency         string `json:"urgency,omitempty"`
+	Asset           string `json:"asset,omitempty"`
+	ReleaseURL      string `json:"release_url,omitempty"`
+	ReleaseNotes    string `json:"release_notes,omitempty"`
+	EligibleAt      string `json:"eligible_at,omitempty"`
+	Revision        string `json:"revision,omitempty"`
+	GoVersion       string `json:"go_version,omitempty"`
+	Status          string `json:"status"`
+	Error           string `json:"error,omitempty"`
+}
+
+type options struct {
+	repo    string
+	binary  string
+	version string
+	filter  string
+	minAge  time.Duration
+	json    bool
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+
+	cmd := args[0]
+	fs := flag.NewFlagSet("gh-update "+cmd, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	var o options
+	fs.StringVar(&o.binary, "binary", "", "Path to the binary to update")
+	fs.BoolVar(&o.json, "json", false, "Print the result as JSON")
+	if cmd != "rollback" {
+		fs.StringVar(&o.repo, "repo", "", "GitHub repository of the binary, e.g. dhcgn/gh-update")
+		fs.StringVar(&o.version, "version", "", "Current version of the binary, read from the Go build info if empty")
+		fs.StringVar(&o.filter, "filter", update.AutoAssetFilter, "Regex to select the release asset, \"auto\" for automatic selection by OS and architecture")
+		fs.DurationVar(&o.minAge, "min-age", 0, "Only use releases published at least this long ago, e.g. 48h")
+	}
+
+	switch cmd {
+	case "check", "apply", "rollback":
+	default:
+		usage(stderr)
+		return exitUsage
+	}
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	if o.binary == "" || (cmd != "rollback" && o.repo == "") {
+		fs.Usage()
+		return exitUsage
+	}
+
+	r := result{Command: cmd, Binary: o.binary, Repository: o.repo}
+	code := exitOK
+	var err error
+	switch cmd {
+	case "check", "apply":
+		code, err = checkOrApply(cmd == "apply", o, &r)
+	case "rollback":
+		err = update.Rollback(o.binary)
+		r.Status = "rolled-back"
+	}
+	if err != nil {
+		r.Status = "error"
+		r.Error = err.Error()
+		if code == exitOK || code == exitError {
+			code = exitCode(err)
+		}
+	}
+
+	printResult(stdout, o.json, r)
+	return code
+}
+
+func checkOrApply(apply bool, o options, r *result) (int, error) {
+	if owner, name, ok := strings.Cut(o.repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return exitUsage, fmt.Errorf("invalid -repo %q, want owner/repo", o.repo)
+	}
+	if o.filter != update.AutoAssetFilter {
+		if _, err := regexp.Compile(o.filter); err != nil {
+			return exitUsage, fmt.Errorf("invalid -filter: %w", err)
+		}
+	}
+	if o.version == "" {
+		v, err := binaryVersion(o.binary)
+		if err != nil {
+			return exitError, err
+		}
+		o.version = v
+	}
+	r.CurrentVersion = o.version
+
+	updater, err := update.New(
+		update.WithRepository(o.repo),
+		update.WithVersion(o.version),
+		update.WithAssetFilter(o.filter),
+		update.WithMinReleaseAge(o.minAge),
+	)
+	if err != nil {
+		return exitUsage, err
+	}
+
+	latest, err := updater.GetLatestVersion()
+	var ageErr *update.ReleaseAgeError
+	if errors.As(err, &ageErr) {
+		r.LatestVersion = ageErr.Version
+		r.EligibleAt = ageErr.EligibleAt.Format(time.RFC3339)
+		r.Status = "not-eligible"
+		return exitOK, nil
+	}
+	if errors.Is(err, update.ErrorNoNewVersionFound) {
+		r.LatestVersion = o.version
+		r.Status = "up-to-date"
+		return exitOK, nil
+	}
+	if err != nil {
+		return exitError, err
+	}
+	r.LatestVersion = latest.Version
+	r.Asset = latest.Name
+	r.ReleaseURL = latest.HTMLURL
+	r.ReleaseNotes = latest.Notes
+	r.UpdateAvailable = true
+	r.Urgency = string(latest.Urgency)
+
+	if !apply {
+		r.Status = "update-available"
+		return exitUpdateAvailable, nil
+	}
+
+	applied, err := updater.ApplyWithResult(latest, o.binary)
+	if errors.Is(err, update.ErrorNoNewVersionFound) {
+		// a concurrent run installed the release
+		r.UpdateAvailable = false
+		r.Status = "up-to-date"
+		return exitOK, nil
+	}
+	if err != nil {
+		return exitError, err
+	}
+	if applied.BuildInfo != nil {
+		r.Revision = applied.BuildInfo.Revision
+		r.GoVersion = applied.BuildInfo.GoVersion
+	}
+	r.Status = "updated"
+	return exitOK, nil
+}
+
+func exitCode(err error) int {
+	var (
+		assetErr    *update.AssetSelectionError
+		downloadErr *update.DownloadError
+		swapErr     *update.SwapError
+	)
+	switch {
+	case errors.As(err, &assetErr):
+		return exitAssetSelection
+	case errors.As(err, &downloadErr):
+		return exitDownload
+	case errors.As(err, &swapErr):
+		return exitSwap
+	case errors.Is(err, update.ErrorUpdateInProgress):
+		return exitInProgress
+	}
+	return exitError
+}
+
+func printResult(w io.Writer, asJSON bool, r result) {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		enc.Encode(r)
+		return
+	}
+
+	switch r.Status {
+	case "up-to-date":
+		fmt.Fprintf(w, "%s is up to date (%s)\n", r.Binary, r.CurrentVersion)
+	case "update-available":
+		fmt.Fprintf(w, "Update available for %s: %s -> %s (%s, %s)\n", r.Binary, r.CurrentVersion, r.LatestVersion, r.Asset, r.Urgency)
+	case "updated":
+		fmt.Fprintf(w, "Updated %s: %s -> %s\n", r.Binary, r.CurrentVersion, r.LatestVersion)
+	case "not-eligible":
+		fmt.Fprintf(w, "%s is not eligible before %s, %s stays at %s\n", r.LatestVersion, r.EligibleAt, r.Binary, r.CurrentVersion)
+	case "rolled-back":
+		fmt.Fprintf(w, "Rolled back %s\n", r.Binary)
+	default:
+		fmt.Fprintln(w, "ERROR:", r.Error)
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: gh-update check|apply|rollback -binary path [-repo owner/repo] [-version v1.2.3] [-filter regex] [-min-age 24h] [-json]")
+}
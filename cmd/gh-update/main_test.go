@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	update "github.com/dhcgn/gh-update"
+	"github.com/dhcgn/gh-update/internal"
+)
+
+func TestRunUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "no command", args: nil},
+		{name: "unknown command", args: []string{"upgrade", "-binary", "myapp"}},
+		{name: "unknown flag", args: []string{"check", "-binary", "myapp", "-repo", "owner/repo", "-force"}},
+		{name: "missing binary", args: []string{"check", "-repo", "owner/repo"}},
+		{name: "missing repo", args: []string{"apply", "-binary", "myapp"}},
+		{name: "invalid repo", args: []string{"check", "-binary", "myapp", "-repo", "owner", "-version", "v1.0.0"}},
+		{name: "invalid filter", args: []string{"check", "-binary", "myapp", "-repo", "owner/repo", "-version", "v1.0.0", "-filter", "(", "-json"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(tt.args, &stdout, &stderr); code != exitUsage {
+				t.Errorf("run() = %d, want %d, stdout %q, stderr %q", code, exitUsage, stdout.String(), stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunJSON(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "myapp")
+	os.WriteFile(binary, []byte("new"), 0755)
+	os.WriteFile(binary+".old", []byte("old"), 0755)
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "rollback", args: []string{"rollback", "-binary", binary, "-json"}, wantCode: exitOK, wantStatus: "rolled-back"},
+		{name: "rollback without backup", args: []string{"rollback", "-binary", binary, "-json"}, wantCode: exitSwap, wantStatus: "error"},
+		{name: "no version in binary", args: []string{"check", "-binary", binary, "-repo", "owner/repo", "-json"}, wantCode: exitError, wantStatus: "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tt.args, &stdout, &stderr)
+			if code != tt.wantCode {
+				t.Errorf("run() = %d, want %d, stdout %q", code, tt.wantCode, stdout.String())
+			}
+			var r result
+			if err := json.Unmarshal(stdout.Bytes(), &r); err != nil {
+				t.Fatalf("output %q is not JSON: %v", stdout.String(), err)
+			}
+			if r.Status != tt.wantStatus || r.Command != tt.args[0] || r.Binary != binary {
+				t.Errorf("result = %+v, want status %v", r, tt.wantStatus)
+			}
+			if tt.wantStatus == "error" && r.Error == "" {
+				t.Errorf("result has no error message")
+			}
+		})
+	}
+
+	if b, _ := os.ReadFile(binary); string(b) != "old" {
+		t.Errorf("binary after rollback = %q, want old", b)
+	}
+}
+
+func TestRunText(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"rollback", "-binary", filepath.Join(t.TempDir(), "missing")}, &stdout, &stderr)
+	if code != exitSwap || !strings.HasPrefix(stdout.String(), "ERROR:") {
+		t.Errorf("run() = %d, %q, want %d and an error", code, stdout.String(), exitSwap)
+	}
+}
+
+func TestRunInProgress(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "myapp")
+	os.WriteFile(binary, []byte("new"), 0755)
+	os.WriteFile(binary+".old", []byte("old"), 0755)
+
+	// another run updates the binary
+	unlock, err := internal.FileOperationsImpl{}.Lock(binary + ".lock")
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("no file locks on this system")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"rollback", "-binary", binary}, &stdout, &stderr); code != exitInProgress {
+		t.Errorf("run() = %d, want %d, stdout %q", code, exitInProgress, stdout.String())
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{err: &update.AssetSelectionError{}, want: exitAssetSelection},
+		{err: &update.DownloadError{Err: errors.New("404")}, want: exitDownload},
+		{err: &update.SwapError{Err: os.ErrPermission}, want: exitSwap},
+		{err: fmt.Errorf("%w: myapp.lock is locked", update.ErrorUpdateInProgress), want: exitInProgress},
+		{err: errors.New("other"), want: exitError},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%T) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
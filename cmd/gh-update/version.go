@@ -0,0 +1,18 @@
+package main
+
+import (
+	"debug/buildinfo"
+	"fmt"
+)
+
+// binaryVersion returns the module version from the Go build info of the binary at path.
+func binaryVersion(path string) (string, error) {
+	info, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read version of %s, use -version: %w", path, err)
+	}
+	if info.Main.Version == "" || info.Main.Version == "(devel)" {
+		return "", fmt.Errorf("%s has no module version, use -version", path)
+	}
+	return info.Main.Version, nil
+}
@@ -0,0 +1,199 @@
+package update
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dhcgn/gh-update/internal"
+)
+
+// WithCompanionFiles installs additional members of the zip asset next to the executable.
+// files maps an archive member to a target path relative to the directory of the executable,
+// a member ending with "/" is a directory and all files below it are installed, e.g.
+//
+//	map[string]string{"plugins/": "plugins", "schema.json": "config/schema.json"}
+//
+// The companion files are staged, backed up and replaced together with the executable,
+// if one of them fails all are restored. Delta patches are not used with companion files.
+func WithCompanionFiles(files map[string]string) Option {
+	return func(u *Updater) {
+		u.companions = files
+	}
+}
+
+// WithExecutableMember sets the archive member of the executable,
+// by default it is the only member which is not a companion file.
+func WithExecutableMember(name string) Option {
+	return func(u *Updater) {
+		u.exeMember = name
+	}
+}
+
+// updateFile is a file of an update.
+type updateFile struct {
+	// target is the path of the file or directory which is replaced.
+	target string
+	// rel is the path of the file below target if target is a directory.
+	rel  string
+	data []byte
+}
+
+// companionTargets returns the target paths of the companion files, sorted.
+func (u *Updater) companionTargets(exepath string) []string {
+	targets := make([]string, 0, len(u.companions))
+	for _, target := range u.companions {
+		targets = append(targets, filepath.Join(filepath.Dir(exepath), filepath.FromSlash(target)))
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// companionFiles splits the members of the archive into the executable and the companion files.
+func (u *Updater) companionFiles(files []internal.ArchiveFile, exepath string) ([]updateFile, error) {
+	dir := filepath.Dir(exepath)
+	result := []updateFile{{target: exepath}}
+	exeFound := false
+	found := make(map[string]bool)
+
+	for _, f := range files {
+		member, target, rel, ok := u.matchCompanion(f.Name)
+		if ok {
+			if !filepath.IsLocal(filepath.FromSlash(target)) || (rel != "" && !filepath.IsLocal(filepath.FromSlash(rel))) {
+				return nil, fmt.Errorf("companion file %s escapes the directory of the executable", f.Name)
+			}
+			found[member] = true
+			result = append(result, updateFile{
+				target: filepath.Join(dir, filepath.FromSlash(target)),
+				rel:    filepath.FromSlash(rel),
+				data:   f.Data,
+			})
+			continue
+		}
+
+		if u.exeMember != "" && f.Name != u.exeMember {
+			continue
+		}
+		if exeFound {
+			return nil, fmt.Errorf("multiple executables in archive, use WithExecutableMember")
+		}
+		exeFound = true
+		result[0].data = f.Data
+	}
+
+	if !exeFound {
+		return nil, fmt.Errorf("executable not found in archive")
+	}
+	for member := range u.companions {
+		if !found[member] {
+			return nil, fmt.Errorf("companion file %s not found in archive", member)
+		}
+	}
+	return result, nil
+}
+
+// matchCompanion returns the companion member, the target and the path below the target of an archive member.
+// If several directory members contain name, the longest one is used.
+func (u *Updater) matchCompanion(name string) (member, target, rel string, ok bool) {
+	if target, ok := u.companions[name]; ok && !strings.HasSuffix(name, "/") {
+		return name, target, "", true
+	}
+	for m, t := range u.companions {
+		if strings.HasSuffix(m, "/") && strings.HasPrefix(name, m) && len(m) > len(member) {
+			member, target, ok = m, t, true
+		}
+	}
+	if !ok {
+		return "", "", "", false
+	}
+	return member, target, strings.TrimPrefix(name, member), true
+}
+
+// stage saves the files of the update next to their targets and returns the targets.
+func (u *Updater) stage(files []updateFile) ([]string, error) {
+	targets := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, f := range files {
+		staged, err := u.fops.CreateNewTempPath(f.target)
+		if err != nil {
+			return nil, &SwapError{Stage: StageSave, From: f.target, Err: err}
+		}
+		if !seen[f.target] {
+			seen[f.target] = true
+			targets = append(targets, f.target)
+			if f.rel != "" {
+				if err := u.fops.RemoveAll(staged); err != nil {
+					return nil, &SwapError{Stage: StageSave, To: staged, Err: err}
+				}
+			}
+		}
+
+		path := staged
+		if f.rel != "" {
+			path = filepath.Join(staged, f.rel)
+		}
+		if err := u.fops.SaveTo(f.data, path); err != nil {
+			return nil, &SwapError{Stage: StageSave, To: path, Err: err}
+		}
+	}
+	return targets, nil
+}
+
+// swap replaces all targets with their staged files as one unit, the replaced files are kept as backup.
+// If a step fails, the targets which were already replaced are restored.
+func (u *Updater) swap(targets []string) error {
+	type swapped struct {
+		target   string
+		backedUp bool
+	}
+	done := make([]swapped, 0, len(targets))
+	restore := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			d := done[i]
+			u.log.Warn("restore after failed update", "path", d.target)
+			if err := u.fops.RemoveAll(d.target); err != nil {
+				u.log.Error("restore failed", "path", d.target, "error", err)
+				continue
+			}
+			if d.backedUp {
+				if err := u.fops.MoveNewExeToOriginalExe(internal.BackupPath(d.target), d.target); err != nil {
+					u.log.Error("restore failed", "path", d.target, "error", err)
+				}
+			}
+		}
+	}
+
+	for _, target := range targets {
+		staged, err := u.fops.CreateNewTempPath(target)
+		if err != nil {
+			restore()
+			return &SwapError{Stage: StageReplace, To: target, Err: err}
+		}
+
+		s := swapped{target: target}
+		if u.fops.Exists(target) {
+			backup := internal.BackupPath(target)
+			if u.fops.Exists(backup) {
+				if err := u.fops.RemoveAll(backup); err != nil {
+					u.log.Warn("remove stale backup failed", "path", backup, "error", err)
+				}
+			}
+			if err := u.fops.MoveRunningExeToBackup(target); err != nil {
+				restore()
+				return &SwapError{Stage: StageBackup, From: target, To: backup, Err: err}
+			}
+			s.backedUp = true
+		}
+
+		if err := u.fops.MoveNewExeToOriginalExe(staged, target); err != nil {
+			if s.backedUp {
+				done = append(done, swapped{target: target, backedUp: true})
+			}
+			restore()
+			return &SwapError{Stage: StageReplace, From: staged, To: target, Err: err}
+		}
+		done = append(done, s)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package update
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dhcgn/gh-update/internal"
+)
+
+func zipFiles(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// FailingReplaceFileOperations fails to move the staged file to target.
+type FailingReplaceFileOperations struct {
+	internal.FileOperationsImpl
+	target string
+}
+
+// MoveNewExeToOriginalExe implements internal.FileOperations
+func (f FailingReplaceFileOperations) MoveNewExeToOriginalExe(newPath string, oldPath string) error {
+	if oldPath == f.target && strings.HasSuffix(newPath, ".new.temp") {
+		return os.ErrPermission
+	}
+	return f.FileOperationsImpl.MoveNewExeToOriginalExe(newPath, oldPath)
+}
+
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	tree := make(map[string]string)
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, _ := os.ReadFile(path)
+		rel, _ := filepath.Rel(dir, path)
+		tree[filepath.ToSlash(rel)] = string(b)
+		return nil
+	})
+	return tree
+}
+
+func TestCompanionFiles(t *testing.T) {
+	asset := zipFiles(t, map[string]string{
+		"myapp.exe":          "new exe",
+		"plugins/a.plugin":   "new a",
+		"plugins/sub/b.conf": "new b",
+		"schema.json":        "new schema",
+	})
+	companions := map[string]string{"plugins/": "plugins", "schema.json": "config/schema.json"}
+
+	setup := func(t *testing.T) (string, string) {
+		dir := t.TempDir()
+		exe := filepath.Join(dir, "myapp.exe")
+		os.WriteFile(exe, []byte("old exe"), 0755)
+		os.MkdirAll(filepath.Join(dir, "plugins"), 0755)
+		os.WriteFile(filepath.Join(dir, "plugins", "old.plugin"), []byte("old plugin"), 0644)
+		return dir, exe
+	}
+	newUpdater := func(t *testing.T, opts ...Option) *Updater {
+		opts = append([]Option{
+			WithRepository("owner/repo"),
+			WithVersion("v0.0.2"),
+			WithAssetFilter("^myapp-.*windows.*zip$"),
+			WithSource(&AssetWebOperationsMock{assets: map[string][]byte{"https://myapp-v0.0.3-windows-amd64.zip": asset}}),
+			WithCompanionFiles(companions),
+			WithExecutableCheck(false),
+		}, opts...)
+		u, err := New(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	latest := LatestRelease{Name: "myapp-v0.0.3-windows-amd64.zip", Url: "https://myapp-v0.0.3-windows-amd64.zip", Version: "v1.2.3"}
+
+	t.Run("apply and rollback", func(t *testing.T) {
+		dir, exe := setup(t)
+		u := newUpdater(t)
+
+		if err := u.Apply(latest, exe); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		got := readTree(t, dir)
+		want := map[string]string{
+			"myapp.exe":              "new exe",
+			"myapp.exe.old":          "old exe",
+			"myapp.exe.lock":         "",
+			"plugins/a.plugin":       "new a",
+			"plugins/sub/b.conf":     "new b",
+			"plugins.old/old.plugin": "old plugin",
+			"config/schema.json":     "new schema",
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("Apply() %s = %q, want %q", k, got[k], v)
+			}
+		}
+		if len(got) != len(want) {
+			t.Errorf("Apply() files = %v", got)
+		}
+
+		if err := u.Rollback(exe); err != nil {
+			t.Fatalf("Rollback() error = %v", err)
+		}
+		got = readTree(t, dir)
+		if got["myapp.exe"] != "old exe" || got["plugins/old.plugin"] != "old plugin" || got["plugins/a.plugin"] != "" {
+			t.Errorf("Rollback() files = %v", got)
+		}
+	})
+
+	t.Run("failed swap restores all", func(t *testing.T) {
+		dir, exe := setup(t)
+		u := newUpdater(t)
+		u.fops = FailingReplaceFileOperations{target: filepath.Join(dir, "plugins")}
+
+		var swapErr *SwapError
+		if err := u.Apply(latest, exe); !errors.As(err, &swapErr) || swapErr.Stage != StageReplace {
+			t.Fatalf("Apply() error = %v, want *SwapError in stage replace", err)
+		}
+		got := readTree(t, dir)
+		if got["myapp.exe"] != "old exe" || got["plugins/old.plugin"] != "old plugin" {
+			t.Errorf("Apply() did not restore the files: %v", got)
+		}
+	})
+
+	t.Run("only companions changed", func(t *testing.T) {
+		dir, exe := setup(t)
+		apply := func(files map[string]string) error {
+			t.Helper()
+			u := newUpdater(t, WithSource(&AssetWebOperationsMock{assets: map[string][]byte{latest.Url: zipFiles(t, files)}}))
+			return u.Apply(latest, exe)
+		}
+		release := map[string]string{"myapp.exe": "new exe", "plugins/a.plugin": "new a", "plugins/sub/b.conf": "new b", "schema.json": "new schema"}
+		if err := apply(release); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		if err := apply(release); !errors.Is(err, ErrorNoNewVersionFound) {
+			t.Fatalf("Apply() of the installed release error = %v, want ErrorNoNewVersionFound", err)
+		}
+
+		release["schema.json"] = "changed schema"
+		if err := apply(release); err != nil {
+			t.Fatalf("Apply() with a changed companion error = %v", err)
+		}
+		if got := readTree(t, dir)["config/schema.json"]; got != "changed schema" {
+			t.Errorf("Apply() schema = %q, want changed schema", got)
+		}
+
+		delete(release, "plugins/sub/b.conf")
+		if err := apply(release); err != nil {
+			t.Fatalf("Apply() with a removed companion file error = %v", err)
+		}
+		if got, ok := readTree(t, dir)["plugins/sub/b.conf"]; ok {
+			t.Errorf("Apply() kept the removed plugins/sub/b.conf = %q", got)
+		}
+	})
+
+	t.Run("missing companion", func(t *testing.T) {
+		_, exe := setup(t)
+		u := newUpdater(t, WithCompanionFiles(map[string]string{"plugins/": "plugins", "schema.json": "schema.json", "missing.txt": "missing.txt"}))
+
+		if err := u.Apply(latest, exe); err == nil || !strings.Contains(err.Error(), "missing.txt") {
+			t.Fatalf("Apply() error = %v, want missing companion", err)
+		}
+		if got, _ := os.ReadFile(exe); string(got) != "old exe" {
+			t.Errorf("Apply() changed the executable")
+		}
+	})
+
+	t.Run("path traversal", func(t *testing.T) {
+		_, exe := setup(t)
+		u := newUpdater(t, WithCompanionFiles(map[string]string{"schema.json": "../schema.json", "plugins/": "plugins"}))
+
+		if err := u.Apply(latest, exe); err == nil {
+			t.Fatal("Apply() error = nil, want error for target outside of the directory")
+		}
+	})
+}
+
+func TestMatchCompanionOverlapping(t *testing.T) {
+	u := &Updater{companions: map[string]string{
+		"plugins/":     "plugins",
+		"plugins/x/":   "x-plugins",
+		"plugins/x/y/": "y-plugins",
+		"plugins/x":    "x-file",
+	}}
+	tests := []struct {
+		name   string
+		target string
+		rel    string
+	}{
+		{name: "plugins/a.plugin", target: "plugins", rel: "a.plugin"},
+		{name: "plugins/x/b.plugin", target: "x-plugins", rel: "b.plugin"},
+		{name: "plugins/x/y/c.plugin", target: "y-plugins", rel: "c.plugin"},
+		{name: "plugins/x", target: "x-file", rel: ""},
+	}
+	for _, tt := range tests {
+		// map iteration order is random, repeat to make a wrong match likely
+		for i := 0; i < 100; i++ {
+			_, target, rel, ok := u.matchCompanion(tt.name)
+			if !ok || target != tt.target || rel != tt.rel {
+				t.Fatalf("matchCompanion(%q) = %q, %q, %v, want %q, %q", tt.name, target, rel, ok, tt.target, tt.rel)
+			}
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package delta
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const magic = "GHUDIFF1"
+
+// MaxSize is the largest file Apply creates, a patch for a larger file is rejected with ErrorPatchTooLarge.
+const MaxSize = 1 << 31
+
+var (
+	ErrorInvalidPatch  = fmt.Errorf("invalid patch")
+	ErrorOldMismatch   = fmt.Errorf("patch was not created for this file")
+	ErrorHashMismatch  = fmt.Errorf("patched file does not match the expected hash")
+	ErrorPatchTooLarge = fmt.Errorf("patch exceeds the expected size")
+)
+
+// header is the fixed size start of a patch, followed by the gzip compressed
+// control, diff and extra blocks.
+type header struct {
+	Magic    [8]byte
+	OldHash  [sha256.Size]byte
+	NewHash  [sha256.Size]byte
+	NewSize  int64
+	CtrlLen  int64
+	DiffLen  int64
+	ExtraLen int64
+}
+
+// Name returns the conventional asset name of a patch from version from to version to,
+// e.g. Name("myapp", "v1.2.0", "v1.3.0") is "myapp_v1.2.0_to_v1.3.0.patch".
+func Name(prefix, from, to string) string {
+	return fmt.Sprintf("%s_%s_to_%s.patch", prefix, from, to)
+}
+
+// ExpectedHash returns the SHA-256 of the file the patch produces.
+func ExpectedHash(patch []byte) ([sha256.Size]byte, error) {
+	h, err := readHeader(patch)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return h.NewHash, nil
+}
+
+// Diff creates a patch which transforms old into new.
+// The memory usage is roughly 17 times the size of old.
+func Diff(old, new []byte) ([]byte, error) {
+	I := make([]int, len(old)+1)
+	V := make([]int, len(old)+1)
+	qsufsort(I, V, old)
+	V = nil
+
+	var ctrl, db, eb bytes.Buffer
+	oldsize, newsize := len(old), len(new)
+
+	var scan, pos, length, lastscan, lastpos, lastoffset int
+	for scan < newsize {
+		oldscore := 0
+		scan += length
+		for scsc := scan; scan < newsize; scan++ {
+			pos, length = search(I, old, new[scan:], 0, oldsize)
+
+			for ; scsc < scan+length; scsc++ {
+				if scsc+lastoffset < oldsize && old[scsc+lastoffset] == new[scsc] {
+					oldscore++
+				}
+			}
+			if (length == oldscore && length != 0) || length > oldscore+8 {
+				break
+			}
+			if scan+lastoffset < oldsize && old[scan+lastoffset] == new[scan] {
+				oldscore--
+			}
+		}
+
+		if length == oldscore && scan != newsize {
+			continue
+		}
+
+		var s, sf, lenf int
+		for i := 0; lastscan+i < scan && lastpos+i < oldsize; {
+			if old[lastpos+i] == new[lastscan+i] {
+				s++
+			}
+			i++
+			if s*2-i > sf*2-lenf {
+				sf = s
+				lenf = i
+			}
+		}
+
+		lenb := 0
+		if scan < newsize {
+			var s, sb int
+			for i := 1; scan >= lastscan+i && pos >= i; i++ {
+				if old[pos-i] == new[scan-i] {
+					s++
+				}
+				if s*2-i > sb*2-lenb {
+					sb = s
+					lenb = i
+				}
+			}
+		}
+
+		if lastscan+lenf > scan-lenb {
+			overlap := (lastscan + lenf) - (scan - lenb)
+			var s, ss, lens int
+			for i := 0; i < overlap; i++ {
+				if new[lastscan+lenf-overlap+i] == old[lastpos+lenf-overlap+i] {
+					s++
+				}
+				if new[scan-lenb+i] == old[pos-lenb+i] {
+					s--
+				}
+				if s > ss {
+					ss = s
+					lens = i + 1
+				}
+			}
+			lenf += lens - overlap
+			lenb -= lens
+		}
+
+		for i := 0; i < lenf; i++ {
+			db.WriteByte(new[lastscan+i] - old[lastpos+i])
+		}
+		extra := (scan - lenb) - (lastscan + lenf)
+		eb.Write(new[lastscan+lenf : lastscan+lenf+extra])
+
+		binary.Write(&ctrl, binary.BigEndian, [3]int64{
+			int64(lenf),
+			int64(extra),
+			int64((pos - lenb) - (lastpos + lenf)),
+		})
+
+		lastscan = scan - lenb
+		lastpos = pos - lenb
+		lastoffset = pos - scan
+	}
+
+	blocks := make([][]byte, 3)
+	for i, b := range []*bytes.Buffer{&ctrl, &db, &eb} {
+		c, err := compress(b.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		blocks[i] = c
+	}
+
+	h := header{
+		OldHash:  sha256.Sum256(old),
+		NewHash:  sha256.Sum256(new),
+		NewSize:  int64(newsize),
+		CtrlLen:  int64(len(blocks[0])),
+		DiffLen:  int64(len(blocks[1])),
+		ExtraLen: int64(len(blocks[2])),
+	}
+	copy(h.Magic[:], magic)
+
+	var out bytes.Buffer
+	if err := binary.Write(&out, binary.BigEndian, h); err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		out.Write(b)
+	}
+	return out.Bytes(), nil
+}
+
+// Apply applies patch to old and returns the new file.
+// It returns ErrorOldMismatch if the patch was created for a different file
+// and ErrorHashMismatch if the result does not match the hash recorded in the patch.
+func Apply(old, patch []byte) ([]byte, error) {
+	h, err := readHeader(patch)
+	if err != nil {
+		return nil, err
+	}
+	if sha256.Sum256(old) != h.OldHash {
+		return nil, ErrorOldMismatch
+	}
+
+	// the lengths are untrusted, compare them without overflow
+	body := patch[binary.Size(h):]
+	n := int64(len(body))
+	if h.CtrlLen < 0 || h.DiffLen < 0 || h.ExtraLen < 0 ||
+		h.CtrlLen > n || h.DiffLen > n-h.CtrlLen || h.ExtraLen != n-h.CtrlLen-h.DiffLen {
+		return nil, ErrorInvalidPatch
+	}
+
+	ctrl, err := gzip.NewReader(bytes.NewReader(body[:h.CtrlLen]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidPatch, err)
+	}
+	diff, err := gzip.NewReader(bytes.NewReader(body[h.CtrlLen : h.CtrlLen+h.DiffLen]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidPatch, err)
+	}
+	extra, err := gzip.NewReader(bytes.NewReader(body[h.CtrlLen+h.DiffLen:]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidPatch, err)
+	}
+
+	// grow the result with the decompressed data instead of trusting NewSize for the allocation
+	var out bytes.Buffer
+	out.Grow(int(min(h.NewSize, 64<<20)))
+	var oldpos int64
+	for newpos := int64(0); newpos < h.NewSize; {
+		var c [3]int64
+		if err := binary.Read(ctrl, binary.BigEndian, &c); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrorInvalidPatch, err)
+		}
+		if c[0] < 0 || c[1] < 0 || c[0] > h.NewSize-newpos {
+			return nil, ErrorPatchTooLarge
+		}
+
+		if _, err := io.CopyN(&out, diff, c[0]); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrorInvalidPatch, err)
+		}
+		added := out.Bytes()[newpos:]
+		for i := range added {
+			if o := oldpos + int64(i); o >= 0 && o < int64(len(old)) {
+				added[i] += old[o]
+			}
+		}
+		newpos += c[0]
+		oldpos += c[0]
+
+		if c[1] > h.NewSize-newpos {
+			return nil, ErrorPatchTooLarge
+		}
+		if _, err := io.CopyN(&out, extra, c[1]); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrorInvalidPatch, err)
+		}
+		newpos += c[1]
+		oldpos += c[2]
+	}
+
+	new := out.Bytes()
+	if sha256.Sum256(new) != h.NewHash {
+		return nil, ErrorHashMismatch
+	}
+	return new, nil
+}
+
+func readHeader(patch []byte) (header, error) {
+	var h header
+	if err := binary.Read(bytes.NewReader(patch), binary.BigEndian, &h); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return header{}, ErrorInvalidPatch
+		}
+		return header{}, err
+	}
+	if string(h.Magic[:]) != magic || h.NewSize < 0 {
+		return header{}, ErrorInvalidPatch
+	}
+	if h.NewSize > MaxSize || h.NewSize > math.MaxInt {
+		return header{}, ErrorPatchTooLarge
+	}
+	return h, nil
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,154 @@
+package delta
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func randomBytes(r *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	r.Read(b)
+	return b
+}
+
+func TestDiffApply(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	base := randomBytes(r, 64*1024)
+
+	changed := append([]byte{}, base...)
+	for i := 0; i < 200; i++ {
+		changed[r.Intn(len(changed))] = byte(r.Intn(256))
+	}
+	changed = append(changed[:1000], append(randomBytes(r, 5000), changed[1000:]...)...)
+
+	tests := []struct {
+		name string
+		old  []byte
+		new  []byte
+	}{
+		{name: "identical", old: base, new: base},
+		{name: "small changes and insert", old: base, new: changed},
+		{name: "empty old", old: nil, new: base[:100]},
+		{name: "empty new", old: base, new: nil},
+		{name: "text", old: []byte("hello world, this is version one"), new: []byte("hello world, this is version two!")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := Diff(tt.old, tt.new)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			got, err := Apply(tt.old, patch)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.new) {
+				t.Errorf("Apply() result differs from new")
+			}
+		})
+	}
+}
+
+func TestPatchIsSmall(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	old := randomBytes(r, 256*1024)
+	new := append([]byte{}, old...)
+	copy(new[5000:], randomBytes(r, 100))
+
+	patch, err := Diff(old, new)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if len(patch) > 4*1024 {
+		t.Errorf("Diff() patch size = %d, want less than 4 KiB", len(patch))
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	old := []byte("the quick brown fox jumps over the lazy dog")
+	new := []byte("the quick brown cat jumps over the lazy dog")
+	patch, err := Diff(old, new)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+
+	corrupted := append([]byte{}, patch...)
+	corrupted[len(magic)+32] ^= 0xff
+
+	tests := []struct {
+		name  string
+		old   []byte
+		patch []byte
+		want  error
+	}{
+		{name: "wrong old file", old: new, patch: patch, want: ErrorOldMismatch},
+		{name: "not a patch", old: old, patch: []byte("MZ not a patch"), want: ErrorInvalidPatch},
+		{name: "wrong expected hash", old: old, patch: corrupted, want: ErrorHashMismatch},
+		{name: "truncated", old: old, patch: patch[:len(patch)-10], want: ErrorInvalidPatch},
+		{name: "huge new size", old: old, patch: craftPatch(t, old, 1<<62, nil), want: ErrorPatchTooLarge},
+		{name: "overflowing lengths", old: old, patch: withLengths(patch, math.MaxInt64, math.MaxInt64, 2), want: ErrorInvalidPatch},
+		{name: "negative length", old: old, patch: withLengths(patch, -1, 0, 0), want: ErrorInvalidPatch},
+		{name: "huge control entry", old: old, patch: craftPatch(t, old, 10, []int64{math.MaxInt64, 0, 0}), want: ErrorPatchTooLarge},
+		{name: "huge extra entry", old: old, patch: craftPatch(t, old, 10, []int64{0, math.MaxInt64, 0}), want: ErrorPatchTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Apply(tt.old, tt.patch); !errors.Is(err, tt.want) {
+				t.Errorf("Apply() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// craftPatch returns a patch for old with an arbitrary new size and control block.
+func craftPatch(t *testing.T, old []byte, newSize int64, ctrl []int64) []byte {
+	t.Helper()
+	var c bytes.Buffer
+	binary.Write(&c, binary.BigEndian, ctrl)
+	blocks := make([][]byte, 3)
+	for i, data := range [][]byte{c.Bytes(), nil, nil} {
+		b, err := compress(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks[i] = b
+	}
+	h := header{OldHash: sha256.Sum256(old), NewSize: newSize, CtrlLen: int64(len(blocks[0])), DiffLen: int64(len(blocks[1])), ExtraLen: int64(len(blocks[2]))}
+	copy(h.Magic[:], magic)
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.BigEndian, h)
+	for _, b := range blocks {
+		out.Write(b)
+	}
+	return out.Bytes()
+}
+
+// withLengths returns patch with the block lengths of the header replaced.
+func withLengths(patch []byte, ctrl, diff, extra int64) []byte {
+	p := append([]byte{}, patch...)
+	off := len(magic) + 2*sha256.Size + 8
+	for i, v := range []int64{ctrl, diff, extra} {
+		binary.BigEndian.PutUint64(p[off+8*i:], uint64(v))
+	}
+	return p
+}
+
+func FuzzApply(f *testing.F) {
+	old := []byte("the quick brown fox jumps over the lazy dog")
+	patch, err := Diff(old, []byte("the quick brown cat jumps over the lazy dog"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(patch)
+	f.Add(withLengths(patch, math.MaxInt64, math.MaxInt64, 2))
+	f.Fuzz(func(t *testing.T, patch []byte) {
+		// must not panic
+		Apply(old, patch)
+	})
+}
@@ -0,0 +1,9 @@
+/*
+Package delta creates and applies binary patches between two versions of an executable.
+
+The algorithm is the one of bsdiff by Colin Percival, the patch container is specific
+to gh-update: it carries the SHA-256 of the old and the new file so a patch is only
+applied to the executable it was created for and the result can be verified.
+*/
+
+package delta
@@ -0,0 +1,167 @@
+package update
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"github.com/dhcgn/gh-update/internal"
+	"github.com/dhcgn/gh-update/types"
+)
+
+// Rename is a file rename done during an update.
+type Rename struct {
+	From string
+	To   string
+}
+
+// UpdatePlan describes what an update would do, it is the result of DryRun.
+type UpdatePlan struct {
+	CurrentVersion string
+	NewVersion     string
+	Release        LatestRelease
+	ExecutablePath string
+	// StagingPath is the path the new executable is saved to before the swap.
+	StagingPath string
+	// Renames are done in order after the new executable is saved to StagingPath.
+	Renames        []Rename
+	RestartCommand []string
+
+	// DownloadedPath is the temporary file the new executable was downloaded to, or the temporary directory
+	// the asset was extracted to with WithVersionedLayout, empty if DryRun was called without download.
+	// The caller should remove it.
+	DownloadedPath string
+	// PatchApplied is true if the download used the delta patch.
+	PatchApplied bool
+	// Bytes and SHA256 describe the new executable, or the asset with WithVersionedLayout.
+	Bytes  int
+	SHA256 string
+	// Validation is the *ValidationError of the checks Apply runs on the downloaded executable,
+	// nil if they pass or DryRun was called without download.
+	Validation error
+	// BuildInfo of the downloaded executable, nil if it has no Go build information.
+	BuildInfo *types.BuildInfo
+}
+
+// DryRun resolves the latest release and returns what SelfUpdateWithLatestAndRestart would do
+// without touching the executable or restarting, see the func DryRun.
+func (u *Updater) DryRun(runningexepath string, download bool) (UpdatePlan, error) {
+	latest, err := u.GetLatestVersion()
+	if err != nil {
+		return UpdatePlan{}, err
+	}
+	return u.Plan(latest, runningexepath, download)
+}
+
+// Plan returns what SelfUpdateAndRestart would do with latest, see the func DryRun.
+func (u *Updater) Plan(latest LatestRelease, runningexepath string, download bool) (UpdatePlan, error) {
+	if latest.Version == "" || latest.Url == "" || latest.Name == "" {
+		return UpdatePlan{}, ErrorLatestNotValid
+	}
+	if runningexepath == "" {
+		return UpdatePlan{}, ErrorRunningExePathIsEmpty
+	}
+
+	newpath, err := u.fops.CreateNewTempPath(runningexepath)
+	if err != nil {
+		return UpdatePlan{}, err
+	}
+
+	plan := UpdatePlan{
+		CurrentVersion: u.version,
+		NewVersion:     latest.Version,
+		Release:        latest,
+		ExecutablePath: runningexepath,
+		StagingPath:    newpath,
+		Renames: []Rename{
+			{From: runningexepath, To: internal.BackupPath(runningexepath)},
+			{From: newpath, To: runningexepath},
+		},
+		RestartCommand: u.osps.Command(u.restartOptions(runningexepath)),
+	}
+	if u.layoutRoot != "" {
+		versionDir := filepath.Join(u.layoutRoot, layoutVersions, latest.Version)
+		current := filepath.Join(u.layoutRoot, layoutCurrent)
+		plan.StagingPath = versionDir + ".new.temp"
+		plan.Renames = []Rename{
+			{From: plan.StagingPath, To: versionDir},
+			{From: current + ".new.temp", To: current},
+		}
+	}
+	for _, target := range u.companionTargets(runningexepath) {
+		staged, err := u.fops.CreateNewTempPath(target)
+		if err != nil {
+			return UpdatePlan{}, err
+		}
+		plan.Renames = append(plan.Renames,
+			Rename{From: target, To: internal.BackupPath(target)},
+			Rename{From: staged, To: target},
+		)
+	}
+	u.log.Info("dry run", "from", plan.CurrentVersion, "to", plan.NewVersion, "asset", latest.Name, "download", download)
+
+	if !download {
+		return plan, nil
+	}
+	if u.layoutRoot != "" {
+		return u.planVersioned(plan, latest, runningexepath)
+	}
+
+	files, patched, err := u.downloadUpdate(latest, runningexepath)
+	if err != nil {
+		return UpdatePlan{}, err
+	}
+	data := files[0].data
+
+	f, err := os.CreateTemp("", "gh-update-*-"+filepath.Base(runningexepath))
+	if err != nil {
+		return UpdatePlan{}, err
+	}
+	f.Close()
+	// the smoke test executes the download
+	if err := os.Chmod(f.Name(), 0755); err != nil {
+		os.Remove(f.Name())
+		return UpdatePlan{}, err
+	}
+	if err := u.fops.SaveTo(data, f.Name()); err != nil {
+		os.Remove(f.Name())
+		return UpdatePlan{}, &SwapError{Stage: StageSave, To: f.Name(), Err: err}
+	}
+
+	sum := sha256.Sum256(data)
+	plan.DownloadedPath = f.Name()
+	plan.PatchApplied = patched
+	plan.Bytes = len(data)
+	plan.SHA256 = hex.EncodeToString(sum[:])
+	plan.BuildInfo, plan.Validation = u.validate(latest, runningexepath, f.Name())
+	if plan.Validation != nil {
+		u.log.Warn("dry run validation failed", "path", f.Name(), "error", plan.Validation)
+	}
+	return plan, nil
+}
+
+// planVersioned extracts latest into a temporary directory like an update with WithVersionedLayout
+// and validates the executable in it.
+func (u *Updater) planVersioned(plan UpdatePlan, latest LatestRelease, runningexepath string) (UpdatePlan, error) {
+	dir, err := os.MkdirTemp("", "gh-update-*")
+	if err != nil {
+		return UpdatePlan{}, err
+	}
+	data, err := u.downloadVersion(latest, runningexepath, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return UpdatePlan{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	plan.DownloadedPath = dir
+	plan.Bytes = len(data)
+	plan.SHA256 = hex.EncodeToString(sum[:])
+	exe := filepath.Join(dir, u.layoutExecutable(runningexepath))
+	plan.BuildInfo, plan.Validation = u.validate(latest, runningexepath, exe)
+	if plan.Validation != nil {
+		u.log.Warn("dry run validation failed", "path", exe, "error", plan.Validation)
+	}
+	return plan, nil
+}
@@ -0,0 +1,144 @@
+package update
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/dhcgn/gh-update/internal"
+)
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "myapp.exe")
+	if err := os.WriteFile(exe, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	w, _ := zw.Create("myapp.exe")
+	w.Write([]byte("new"))
+	zw.Close()
+
+	u, err := New(
+		WithRepository("owner/repo"),
+		WithVersion("v0.0.2"),
+		WithAssetFilter("^myapp-.*windows.*zip$"),
+		WithSource(&AssetWebOperationsMock{
+			assets: map[string][]byte{"https://myapp-v0.0.3-windows-amd64.zip": zipped.Bytes()},
+		}),
+		WithRestartArgs("serve", "--port", "8080"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		download bool
+	}{
+		{name: "plan only"},
+		{name: "download", download: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := u.DryRun(exe, tt.download)
+			if err != nil {
+				t.Fatalf("DryRun() error = %v", err)
+			}
+
+			if plan.CurrentVersion != "v0.0.2" || plan.NewVersion != "v1.2.3" {
+				t.Errorf("DryRun() versions = %v -> %v", plan.CurrentVersion, plan.NewVersion)
+			}
+			wantRenames := []Rename{
+				{From: exe, To: exe + ".old"},
+				{From: exe + ".new.temp", To: exe},
+			}
+			if !reflect.DeepEqual(plan.Renames, wantRenames) {
+				t.Errorf("DryRun() renames = %v, want %v", plan.Renames, wantRenames)
+			}
+			if !reflect.DeepEqual(plan.RestartCommand, []string{exe, "serve", "--port", "8080"}) {
+				t.Errorf("DryRun() restart = %v", plan.RestartCommand)
+			}
+
+			if tt.download {
+				defer os.Remove(plan.DownloadedPath)
+				got, err := os.ReadFile(plan.DownloadedPath)
+				if err != nil || string(got) != "new" {
+					t.Errorf("DryRun() downloaded %q, %v", got, err)
+				}
+				if plan.SHA256 != "11507a0e2f5e69d5dfa40a62a1bd7b6ee57e6bcd85c67c9b8431b36fff21c437" {
+					t.Errorf("DryRun() SHA256 = %v", plan.SHA256)
+				}
+				// "new" is not an executable, Apply would refuse it
+				var verr *ValidationError
+				if internal.ExecutableFormat(runtime.GOOS) != "" && (!errors.As(plan.Validation, &verr) || verr.Check != CheckExecutable) {
+					t.Errorf("DryRun() Validation = %v, want *ValidationError of the executable check", plan.Validation)
+				}
+			} else if plan.DownloadedPath != "" || plan.Validation != nil {
+				t.Errorf("DryRun() DownloadedPath = %v, Validation = %v, want empty", plan.DownloadedPath, plan.Validation)
+			}
+
+			if got, _ := os.ReadFile(exe); string(got) != "old" {
+				t.Errorf("DryRun() changed the executable")
+			}
+			if _, err := os.Stat(exe + ".old"); !os.IsNotExist(err) {
+				t.Errorf("DryRun() created a backup")
+			}
+		})
+	}
+}
+
+func TestDryRunSmokeTest(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no shell scripts on windows")
+	}
+
+	tests := []struct {
+		name    string
+		script  string
+		wantErr bool
+	}{
+		{name: "passes", script: "#!/bin/sh\necho myapp 1.2.3\n"},
+		{name: "wrong version", script: "#!/bin/sh\necho myapp 0.0.2\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exe := filepath.Join(t.TempDir(), "myapp")
+			os.WriteFile(exe, []byte("old"), 0755)
+
+			u, err := New(
+				WithRepository("owner/repo"),
+				WithVersion("v0.0.2"),
+				WithSource(&AssetWebOperationsMock{assets: map[string][]byte{"https://asset": []byte(tt.script)}}),
+				WithExecutableCheck(false),
+				WithSmokeTest(5*time.Second, "--version"),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			latest := LatestRelease{Name: "myapp-linux-amd64", Url: "https://asset", Version: "v1.2.3"}
+			plan, err := u.Plan(latest, exe, true)
+			if err != nil {
+				t.Fatalf("Plan() error = %v", err)
+			}
+			defer os.Remove(plan.DownloadedPath)
+
+			var verr *ValidationError
+			if tt.wantErr != errors.As(plan.Validation, &verr) {
+				t.Errorf("Plan() Validation = %v, wantErr %v", plan.Validation, tt.wantErr)
+			}
+			if tt.wantErr && verr.Check != CheckSmokeTest {
+				t.Errorf("Plan() Validation check = %v, want %v", verr.Check, CheckSmokeTest)
+			}
+		})
+	}
+}
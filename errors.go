@@ -0,0 +1,183 @@
+package update
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dhcgn/gh-update/internal"
+)
+
+// AssetSelectionError is returned if none or more than one asset of a release matches the asset filter.
+type AssetSelectionError struct {
+	Filter  string
+	Version string
+	// Candidates are the matching assets, empty if none matched.
+	Candidates []string
+}
+
+func (e *AssetSelectionError) Error() string {
+	if len(e.Candidates) == 0 {
+		return fmt.Sprintf("no assets found with filter %s in version %v", e.Filter, e.Version)
+	}
+	return fmt.Sprintf("multiple assets found with filter %s in version %v: %s", e.Filter, e.Version, strings.Join(e.Candidates, ", "))
+}
+
+// ReleaseAgeError is returned if the latest release is younger than the minimum release age, see WithMinReleaseAge.
+// It matches ErrorNoNewVersionFound with errors.Is.
+type ReleaseAgeError struct {
+	Version     string
+	PublishedAt time.Time
+	// EligibleAt is the time the release becomes eligible for the update.
+	EligibleAt time.Time
+}
+
+func (e *ReleaseAgeError) Error() string {
+	return fmt.Sprintf("release %s is too new, eligible at %s", e.Version, e.EligibleAt.Format(time.RFC3339))
+}
+
+func (e *ReleaseAgeError) Is(target error) bool {
+	return target == ErrorNoNewVersionFound
+}
+
+// DownloadError is returned if the release information or an asset could not be downloaded.
+type DownloadError struct {
+	URL string
+	// StatusCode is the HTTP status code of the response, zero if there was no response.
+	StatusCode int
+	Err        error
+}
+
+func (e *DownloadError) Error() string {
+	return fmt.Sprintf("download %s: %v", e.URL, e.Err)
+}
+
+func (e *DownloadError) Unwrap() error {
+	return e.Err
+}
+
+func newDownloadError(url string, err error) error {
+	e := &DownloadError{URL: url, Err: err}
+	var se *internal.StatusError
+	if errors.As(err, &se) {
+		e.StatusCode = se.StatusCode
+	}
+	return e
+}
+
+// SwapStage is the step of replacing the executable which failed.
+type SwapStage string
+
+const (
+	// StageSave is the save of the new executable next to the running one.
+	StageSave SwapStage = "save"
+	// StageBackup is the rename of the running executable to the backup.
+	StageBackup SwapStage = "backup"
+	// StageReplace is the rename of the new executable to the path of the running one.
+	StageReplace SwapStage = "replace"
+	// StageCleanUp is the removal of the backup after the update.
+	StageCleanUp SwapStage = "cleanup"
+	// StageRollback is the restore of the backup.
+	StageRollback SwapStage = "rollback"
+)
+
+// SwapError is returned if the running executable could not be replaced.
+type SwapError struct {
+	Stage SwapStage
+	From  string
+	To    string
+	Err   error
+}
+
+func (e *SwapError) Error() string {
+	return fmt.Sprintf("%s %s -> %s: %v", e.Stage, e.From, e.To, e.Err)
+}
+
+func (e *SwapError) Unwrap() error {
+	return e.Err
+}
+
+// RestartError is returned if the new executable could not be started.
+type RestartError struct {
+	Path string
+	Err  error
+}
+
+func (e *RestartError) Error() string {
+	return fmt.Sprintf("restart %s: %v", e.Path, e.Err)
+}
+
+func (e *RestartError) Unwrap() error {
+	return e.Err
+}
+
+// MigrationError is returned if a migration registered with WithMigration failed.
+type MigrationError struct {
+	Version string
+	Err     error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migration %s: %v", e.Version, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// VetoError is returned if the PreUpdate hook refused or postponed the update, see Hooks.
+type VetoError struct {
+	Reason string
+	// RetryAfter is the time after which the update should be tried again, zero if the update was refused.
+	RetryAfter time.Duration
+	Err        error
+}
+
+// Veto refuses the update in a PreUpdate hook.
+func Veto(reason string) error {
+	return &VetoError{Reason: reason}
+}
+
+// Postpone asks in a PreUpdate hook to try the update again after retryAfter.
+func Postpone(reason string, retryAfter time.Duration) error {
+	return &VetoError{Reason: reason, RetryAfter: retryAfter}
+}
+
+func (e *VetoError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("update postponed for %s: %s", e.RetryAfter, e.Reason)
+	}
+	return fmt.Sprintf("update vetoed: %s", e.Reason)
+}
+
+func (e *VetoError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationCheck is the check of the downloaded executable which failed.
+type ValidationCheck string
+
+const (
+	// CheckExecutable checks the format and architecture of the downloaded executable, see WithExecutableCheck.
+	CheckExecutable ValidationCheck = "executable check"
+	// CheckBuildInfo compares the Go main module of the downloaded executable, see WithBuildInfoCheck.
+	CheckBuildInfo ValidationCheck = "build info check"
+	// CheckSmokeTest executes the downloaded executable, see WithSmokeTest.
+	CheckSmokeTest ValidationCheck = "smoke test"
+)
+
+// ValidationError is returned if the downloaded executable failed a check before it replaced the running one.
+type ValidationError struct {
+	Check ValidationCheck
+	Path  string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s of %s: %v", e.Check, e.Path, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
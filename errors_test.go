@@ -0,0 +1,146 @@
+package update
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/dhcgn/gh-update/internal"
+	"github.com/dhcgn/gh-update/types"
+)
+
+type FailingFileOperationsMock struct {
+	FileOperationsMock
+	backupErr error
+}
+
+// MoveRunningExeToBackup implements internal.FileOperations
+func (m *FailingFileOperationsMock) MoveRunningExeToBackup(p string) error {
+	return m.backupErr
+}
+
+type FailingOsOperationsMock struct {
+	OsOperationsMock
+}
+
+// Restart implements internal.OsOperations
+func (*FailingOsOperationsMock) Restart(opts internal.RestartOptions) (*os.Process, error) {
+	return nil, os.ErrPermission
+}
+
+type FailingWebOperationsMock struct {
+	WebOperationsMock
+	releaseErr error
+	assetErr   error
+	assets     []types.Assets
+}
+
+// GetGithubRelease implements internal.WebOperations
+func (m *FailingWebOperationsMock) GetGithubRelease(url string) (*types.GithubReleaseResult, error) {
+	if m.releaseErr != nil {
+		return nil, m.releaseErr
+	}
+	return &types.GithubReleaseResult{TagName: "v1.2.3", Assets: m.assets}, nil
+}
+
+// GetAssetReader implements internal.WebOperations
+func (m *FailingWebOperationsMock) GetAssetReader(url string) (data []byte, err error) {
+	return nil, m.assetErr
+}
+
+func TestErrorTypes(t *testing.T) {
+	twoAssets := []types.Assets{
+		{Name: "myapp-v1.2.3-windows-amd64.zip", BrowserDownloadURL: "https://a"},
+		{Name: "myapp-v1.2.3-windows-arm64.zip", BrowserDownloadURL: "https://b"},
+	}
+
+	tests := []struct {
+		name  string
+		fops  internal.FileOperations
+		osps  internal.OsOperations
+		webop internal.WebOperations
+		check func(t *testing.T, err error)
+	}{
+		{
+			name:  "multiple assets",
+			webop: &FailingWebOperationsMock{assets: twoAssets},
+			check: func(t *testing.T, err error) {
+				var e *AssetSelectionError
+				if !errors.As(err, &e) || len(e.Candidates) != 2 {
+					t.Errorf("error = %#v, want *AssetSelectionError with 2 candidates", err)
+				}
+			},
+		},
+		{
+			name:  "no assets",
+			webop: &FailingWebOperationsMock{},
+			check: func(t *testing.T, err error) {
+				var e *AssetSelectionError
+				if !errors.As(err, &e) || len(e.Candidates) != 0 {
+					t.Errorf("error = %#v, want *AssetSelectionError without candidates", err)
+				}
+			},
+		},
+		{
+			name:  "release not found",
+			webop: &FailingWebOperationsMock{releaseErr: &internal.StatusError{StatusCode: 404, Status: "404 Not Found"}},
+			check: func(t *testing.T, err error) {
+				var e *DownloadError
+				if !errors.As(err, &e) || e.StatusCode != 404 {
+					t.Errorf("error = %#v, want *DownloadError with status 404", err)
+				}
+			},
+		},
+		{
+			name:  "asset download",
+			webop: &FailingWebOperationsMock{assets: twoAssets[:1], assetErr: os.ErrDeadlineExceeded},
+			check: func(t *testing.T, err error) {
+				var e *DownloadError
+				if !errors.As(err, &e) || e.URL != "https://a" || !errors.Is(err, os.ErrDeadlineExceeded) {
+					t.Errorf("error = %#v, want *DownloadError wrapping the cause", err)
+				}
+			},
+		},
+		{
+			name:  "backup",
+			fops:  &FailingFileOperationsMock{backupErr: os.ErrPermission},
+			webop: &FailingWebOperationsMock{assets: twoAssets[:1]},
+			check: func(t *testing.T, err error) {
+				var e *SwapError
+				if !errors.As(err, &e) || e.Stage != StageBackup || !errors.Is(err, os.ErrPermission) {
+					t.Errorf("error = %#v, want *SwapError in stage backup", err)
+				}
+			},
+		},
+		{
+			name:  "restart",
+			osps:  &FailingOsOperationsMock{},
+			webop: &FailingWebOperationsMock{assets: twoAssets[:1]},
+			check: func(t *testing.T, err error) {
+				var e *RestartError
+				if !errors.As(err, &e) || !errors.Is(err, os.ErrPermission) {
+					t.Errorf("error = %#v, want *RestartError", err)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fops = &FileOperationsMock{}
+			osps = &OsOperationsMock{}
+			webop = tt.webop
+			if tt.fops != nil {
+				fops = tt.fops
+			}
+			if tt.osps != nil {
+				osps = tt.osps
+			}
+
+			err := SelfUpdateWithLatestAndRestart("owner/repo", "v0.0.1", "^myapp-.*windows.*zip$", "myapp.exe")
+			if err == nil {
+				t.Fatal("SelfUpdateWithLatestAndRestart() error = nil")
+			}
+			tt.check(t, err)
+		})
+	}
+}
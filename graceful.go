@@ -0,0 +1,243 @@
+package update
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhcgn/gh-update/internal"
+)
+
+// DefaultReadyTimeout is the time the restarted application has to call Ready if WithGracefulRestart is called without one.
+const DefaultReadyTimeout = 30 * time.Second
+
+// WithGracefulRestart passes listeners to the restarted application, which takes them over with Listen
+// or InheritedListeners and calls Ready when it serves. SelfUpdateAndRestart waits at most timeout for Ready
+// and returns nil after it, then the caller drains its connections, e.g. with http.Server.Shutdown, and exits.
+// If the new application does not get ready, it is killed, a *RestartError is returned and the caller keeps serving.
+// The update is not rolled back then, the executable on disk is the new one, call Rollback to restore the old one.
+// Only TCP and Unix listeners on Unix systems are supported.
+func WithGracefulRestart(timeout time.Duration, listeners ...net.Listener) Option {
+	return func(u *Updater) {
+		if timeout <= 0 {
+			timeout = DefaultReadyTimeout
+		}
+		u.graceful = true
+		u.readyTimeout = timeout
+		u.listeners = listeners
+	}
+}
+
+// gracefulRestart starts the updated executable with the listeners and waits until it is ready.
+// If it does not get ready, it is killed and the listeners stay with this process.
+func (u *Updater) gracefulRestart(opts internal.RestartOptions) (err error) {
+	files := make([]*os.File, 0, len(u.listeners))
+	// *net.UnixListener, the method does not exist on all systems
+	type unlinker interface{ SetUnlinkOnClose(bool) }
+	var unixListeners []unlinker
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+		if err != nil {
+			// this process keeps serving, so it removes the socket file again when it closes the listener
+			for _, ul := range unixListeners {
+				ul.SetUnlinkOnClose(true)
+			}
+		}
+	}()
+
+	descs := make([]string, 0, len(u.listeners))
+	for _, l := range u.listeners {
+		fl, ok := l.(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("listener %s of type %T cannot be passed", l.Addr(), l)
+		}
+		if ul, ok := l.(unlinker); ok {
+			// the socket file must survive the shutdown of this process
+			ul.SetUnlinkOnClose(false)
+			unixListeners = append(unixListeners, ul)
+		}
+		f, err := fl.File()
+		if err != nil {
+			return fmt.Errorf("listener %s: %w", l.Addr(), err)
+		}
+		files = append(files, f)
+		fd := 3 + len(opts.ExtraFiles)
+		opts.ExtraFiles = append(opts.ExtraFiles, f)
+		descs = append(descs, fmt.Sprintf("%d:%s:%s", fd, l.Addr().Network(), l.Addr()))
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	readyFd := 3 + len(opts.ExtraFiles)
+	opts.ExtraFiles = append(opts.ExtraFiles, w)
+	opts.Env = append(opts.Env,
+		internal.EnvListeners+"="+strings.Join(descs, ";"),
+		internal.EnvReadyFd+"="+strconv.Itoa(readyFd),
+	)
+
+	proc, err := u.osps.Restart(opts)
+	// only the new process may hold the write end, so a crash ends the read with EOF
+	w.Close()
+	if err != nil {
+		return err
+	}
+
+	u.log.Info("waiting for restarted application", "timeout", u.readyTimeout, "listeners", len(u.listeners))
+	if err := r.SetReadDeadline(time.Now().Add(u.readyTimeout)); err != nil {
+		u.kill(proc)
+		return err
+	}
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(r, b); err != nil {
+		u.kill(proc)
+		if errors.Is(err, io.EOF) {
+			return errors.New("restarted application exited before it was ready")
+		}
+		return fmt.Errorf("restarted application not ready: %w", err)
+	}
+	u.log.Info("restarted application is ready")
+	return nil
+}
+
+// kill stops a restarted application which did not get ready, so it does not serve next to this process.
+func (u *Updater) kill(proc *os.Process) {
+	if proc == nil {
+		return
+	}
+	if err := proc.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		u.log.Error("kill restarted application failed", "pid", proc.Pid, "error", err)
+		return
+	}
+	if _, err := proc.Wait(); err != nil {
+		u.log.Warn("wait for restarted application failed", "pid", proc.Pid, "error", err)
+	}
+	u.log.Warn("killed restarted application", "pid", proc.Pid)
+}
+
+var inherited struct {
+	once      sync.Once
+	listeners []net.Listener
+	err       error
+}
+
+// InheritedListeners returns the listeners passed by WithGracefulRestart of the application which restarted into this update,
+// it is empty if there are none. Listen takes over a single listener.
+func InheritedListeners() ([]net.Listener, error) {
+	inherited.once.Do(func() {
+		env := os.Getenv(internal.EnvListeners)
+		// the descriptors are taken over once and must not leak into child processes
+		os.Unsetenv(internal.EnvListeners)
+		inherited.listeners, inherited.err = inheritedListeners(env)
+	})
+	return inherited.listeners, inherited.err
+}
+
+func inheritedListeners(env string) ([]net.Listener, error) {
+	if env == "" {
+		return nil, nil
+	}
+	var listeners []net.Listener
+	for _, desc := range strings.Split(env, ";") {
+		fdText, addr, ok := strings.Cut(desc, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid inherited listener %q", desc)
+		}
+		fd, err := strconv.Atoi(fdText)
+		if err != nil {
+			return nil, fmt.Errorf("invalid inherited listener %q: %w", desc, err)
+		}
+		f := os.NewFile(uintptr(fd), addr)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("inherited listener %s: %w", addr, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// Listen returns the inherited listener for network and address, see WithGracefulRestart,
+// or a new one from net.Listen.
+func Listen(network, address string) (net.Listener, error) {
+	listeners, err := InheritedListeners()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range listeners {
+		if sameAddr(l.Addr(), network, address) {
+			return l, nil
+		}
+	}
+	return net.Listen(network, address)
+}
+
+// sameAddr reports if a listener on addr listens on network and address.
+func sameAddr(addr net.Addr, network, address string) bool {
+	if !strings.HasPrefix(network, addr.Network()) {
+		return false
+	}
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return addr.String() == address
+	}
+	want, err := net.ResolveTCPAddr(network, address)
+	if err != nil || want.Port != tcp.Port {
+		return false
+	}
+	if want.IP == nil || want.IP.IsUnspecified() {
+		return tcp.IP.IsUnspecified()
+	}
+	return want.IP.Equal(tcp.IP)
+}
+
+var ready struct {
+	once sync.Once
+	err  error
+}
+
+// Ready signals the application which restarted into this update with WithGracefulRestart that this process serves,
+// after that the old process drains and exits. It does nothing if the process was not started by a graceful restart
+// or Ready was called before.
+func Ready() error {
+	ready.once.Do(func() {
+		env := os.Getenv(internal.EnvReadyFd)
+		os.Unsetenv(internal.EnvReadyFd)
+		ready.err = signalReady(env)
+	})
+	return ready.err
+}
+
+func signalReady(env string) error {
+	if env == "" {
+		return nil
+	}
+	fd, err := strconv.Atoi(env)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", internal.EnvReadyFd, env, err)
+	}
+	f := os.NewFile(uintptr(fd), "ready")
+	defer f.Close()
+	_, err = f.Write([]byte{1})
+	return err
+}
+
+// checkGracefulRestart returns an error if the graceful restart is not supported on this system.
+func (u *Updater) checkGracefulRestart() error {
+	if u.graceful && runtime.GOOS == "windows" {
+		return fmt.Errorf("graceful restart: %w", errors.ErrUnsupported)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+//go:build unix
+
+package update
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/dhcgn/gh-update/internal"
+)
+
+type GracefulOsOperationsMock struct {
+	OsOperationsMock
+	ready bool
+	// hang starts a process which inherits the extra files and never gets ready
+	hang bool
+	opts internal.RestartOptions
+	proc *os.Process
+}
+
+// Restart implements internal.OsOperations
+func (o *GracefulOsOperationsMock) Restart(opts internal.RestartOptions) (*os.Process, error) {
+	o.opts = opts
+	if o.hang {
+		cmd := exec.Command("sleep", "30")
+		cmd.ExtraFiles = opts.ExtraFiles
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		o.proc = cmd.Process
+		return cmd.Process, nil
+	}
+	if o.ready {
+		// the new process writes to the ready pipe, the last extra file
+		_, err := opts.ExtraFiles[len(opts.ExtraFiles)-1].Write([]byte{1})
+		return nil, err
+	}
+	return nil, nil
+}
+
+func TestGracefulRestart(t *testing.T) {
+	tests := []struct {
+		name    string
+		ready   bool
+		wantErr bool
+	}{
+		{name: "ready", ready: true},
+		{name: "exited", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+
+			u, err := New(
+				WithRepository("owner/repo"),
+				WithVersion("v0.0.2"),
+				WithAssetFilter("^myapp-.*windows.*zip$"),
+				WithSource(&WebOperationsMock{}),
+				WithGracefulRestart(5*time.Second, l),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			osps := &GracefulOsOperationsMock{ready: tt.ready}
+			u.fops = &FileOperationsMock{}
+			u.osps = osps
+
+			err = u.SelfUpdateWithLatestAndRestart(`C:\myapp.exe`)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SelfUpdateWithLatestAndRestart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(osps.opts.ExtraFiles) != 2 {
+				t.Fatalf("restart extra files = %v, want listener and ready pipe", osps.opts.ExtraFiles)
+			}
+			env := strings.Join(osps.opts.Env, "\n")
+			for _, want := range []string{internal.EnvListeners + "=3:tcp:" + l.Addr().String(), internal.EnvReadyFd + "=4"} {
+				if !strings.Contains(env, want) {
+					t.Errorf("restart env = %v, want %v", osps.opts.Env, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGracefulRestartNotReady(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "myapp.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := New(
+		WithRepository("owner/repo"),
+		WithVersion("v0.0.2"),
+		WithAssetFilter("^myapp-.*windows.*zip$"),
+		WithSource(&WebOperationsMock{}),
+		WithGracefulRestart(200*time.Millisecond, l),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	osps := &GracefulOsOperationsMock{hang: true}
+	u.fops = &FileOperationsMock{}
+	u.osps = osps
+
+	var restartErr *RestartError
+	if err := u.SelfUpdateWithLatestAndRestart(`C:\myapp.exe`); !errors.As(err, &restartErr) {
+		t.Fatalf("SelfUpdateWithLatestAndRestart() error = %v, want *RestartError", err)
+	}
+	if osps.proc == nil {
+		t.Fatal("restarted application not started")
+	}
+	if err := osps.proc.Signal(syscall.Signal(0)); !errors.Is(err, os.ErrProcessDone) {
+		t.Errorf("restarted application still running after timeout: %v", err)
+	}
+
+	// this process keeps serving and removes the socket file when it closes the listener
+	l.Close()
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Errorf("socket file kept after close: %v", err)
+	}
+}
+
+func TestInheritedListeners(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// inheritedListeners takes over the descriptor like in the new process
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listeners, err := inheritedListeners(fmt.Sprintf("%d:tcp:%s", fd, l.Addr()))
+	if err != nil {
+		t.Fatalf("inheritedListeners() error = %v", err)
+	}
+	if len(listeners) != 1 || listeners[0].Addr().String() != l.Addr().String() {
+		t.Fatalf("inheritedListeners() = %v, want %v", listeners, l.Addr())
+	}
+	listeners[0].Close()
+
+	if _, err := inheritedListeners("tcp:127.0.0.1:8080"); err == nil {
+		t.Errorf("inheritedListeners() without fd error = nil")
+	}
+}
+
+func TestSameAddr(t *testing.T) {
+	tests := []struct {
+		addr    net.Addr
+		network string
+		address string
+		want    bool
+	}{
+		{addr: &net.TCPAddr{IP: net.IPv6unspecified, Port: 8080}, network: "tcp", address: ":8080", want: true},
+		{addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}, network: "tcp4", address: "127.0.0.1:8080", want: true},
+		{addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}, network: "tcp", address: ":8080"},
+		{addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}, network: "tcp", address: "127.0.0.1:8081"},
+		{addr: &net.UnixAddr{Name: "/run/myapp.sock", Net: "unix"}, network: "unix", address: "/run/myapp.sock", want: true},
+		{addr: &net.UnixAddr{Name: "/run/myapp.sock", Net: "unix"}, network: "tcp", address: "/run/myapp.sock"},
+	}
+	for _, tt := range tests {
+		if got := sameAddr(tt.addr, tt.network, tt.address); got != tt.want {
+			t.Errorf("sameAddr(%v, %v, %v) = %v, want %v", tt.addr, tt.network, tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestReady(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	// Ready takes over the descriptor like in the new process
+	fd, err := syscall.Dup(int(w.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(internal.EnvReadyFd, fmt.Sprint(fd))
+	if err := Ready(); err != nil {
+		t.Fatalf("Ready() error = %v", err)
+	}
+	b := make([]byte, 1)
+	if n, err := r.Read(b); n != 1 || err != nil {
+		t.Errorf("ready pipe read = %d, %v", n, err)
+	}
+	if env, ok := os.LookupEnv(internal.EnvReadyFd); ok {
+		t.Errorf("Ready() kept %s=%s", internal.EnvReadyFd, env)
+	}
+
+	// the second call must not write to the closed descriptor, which may be reused by now
+	if err := Ready(); err != nil {
+		t.Errorf("second Ready() error = %v", err)
+	}
+	w.Close()
+	if n, err := r.Read(b); n != 0 || !errors.Is(err, io.EOF) {
+		t.Errorf("ready pipe read after second Ready() = %d, %v, want EOF", n, err)
+	}
+}
+
+func TestSignalReadyWithoutGracefulRestart(t *testing.T) {
+	if err := signalReady(""); err != nil {
+		t.Errorf("signalReady() without graceful restart error = %v", err)
+	}
+}
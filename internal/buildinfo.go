@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"debug/buildinfo"
+
+	"github.com/dhcgn/gh-update/types"
+)
+
+// BuildInfo reads the Go build information of the executable at path.
+func (FileOperationsImpl) BuildInfo(path string) (types.BuildInfo, error) {
+	bi, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return types.BuildInfo{}, err
+	}
+	info := types.BuildInfo{Path: bi.Path, ModulePath: bi.Main.Path, GoVersion: bi.GoVersion}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			info.Time = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info, nil
+}
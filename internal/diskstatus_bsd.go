@@ -0,0 +1,19 @@
+//go:build darwin || freebsd || dragonfly
+
+package internal
+
+import "syscall"
+
+// mntRdonly is the MNT_RDONLY mount flag reported by statfs.
+const mntRdonly = 0x1
+
+func diskStatus(dir string) (DiskStatus, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return DiskStatus{}, err
+	}
+	return DiskStatus{
+		Free:     uint64(st.Bavail) * uint64(st.Bsize),
+		ReadOnly: uint64(st.Flags)&mntRdonly != 0,
+	}, nil
+}
@@ -0,0 +1,17 @@
+package internal
+
+import "syscall"
+
+// stRdonly is the ST_RDONLY mount flag reported by statfs.
+const stRdonly = 0x1
+
+func diskStatus(dir string) (DiskStatus, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return DiskStatus{}, err
+	}
+	return DiskStatus{
+		Free:     st.Bavail * uint64(st.Bsize),
+		ReadOnly: st.Flags&stRdonly != 0,
+	}, nil
+}
@@ -0,0 +1,17 @@
+package internal
+
+import "syscall"
+
+// mntRdonly is the MNT_RDONLY mount flag reported by statfs.
+const mntRdonly = 0x1
+
+func diskStatus(dir string) (DiskStatus, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return DiskStatus{}, err
+	}
+	return DiskStatus{
+		Free:     uint64(st.F_bavail) * uint64(st.F_bsize),
+		ReadOnly: st.F_flags&mntRdonly != 0,
+	}, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !windows && !darwin && !freebsd && !dragonfly && !openbsd
+
+package internal
+
+import "errors"
+
+func diskStatus(dir string) (DiskStatus, error) {
+	return DiskStatus{}, errors.ErrUnsupported
+}
@@ -0,0 +1,22 @@
+package internal
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceExW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+func diskStatus(dir string) (DiskStatus, error) {
+	p, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return DiskStatus{}, err
+	}
+
+	var free uint64
+	r, _, err := procGetDiskFreeSpaceExW.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&free)), 0, 0)
+	if r == 0 {
+		return DiskStatus{}, err
+	}
+	return DiskStatus{Free: free}, nil
+}
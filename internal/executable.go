@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Executable formats returned by ExecutableInfo.
+const (
+	FormatELF   = "elf"
+	FormatPE    = "pe"
+	FormatMachO = "macho"
+)
+
+// ExecutableInfo describes the format of an executable file.
+type ExecutableInfo struct {
+	// Format is one of FormatELF, FormatPE and FormatMachO.
+	Format string
+	// Archs are the GOARCH values the executable runs on, a universal Mach-O file has several.
+	Archs []string
+}
+
+// ExecutableInfo reads the header of the executable at path.
+func (FileOperationsImpl) ExecutableInfo(path string) (ExecutableInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ExecutableInfo{}, err
+	}
+	defer f.Close()
+
+	if ef, err := elf.NewFile(f); err == nil {
+		if ef.Type != elf.ET_EXEC && ef.Type != elf.ET_DYN {
+			return ExecutableInfo{}, fmt.Errorf("elf file of type %s is not an executable", ef.Type)
+		}
+		return ExecutableInfo{Format: FormatELF, Archs: []string{elfArch(ef)}}, nil
+	}
+	if pf, err := pe.NewFile(f); err == nil {
+		if pf.Characteristics&pe.IMAGE_FILE_EXECUTABLE_IMAGE == 0 || pf.Characteristics&pe.IMAGE_FILE_DLL != 0 {
+			return ExecutableInfo{}, errors.New("pe file is not an executable")
+		}
+		return ExecutableInfo{Format: FormatPE, Archs: []string{peArch(pf.Machine)}}, nil
+	}
+	if mf, err := macho.NewFile(f); err == nil {
+		if mf.Type != macho.TypeExec {
+			return ExecutableInfo{}, fmt.Errorf("mach-o file of type %s is not an executable", mf.Type)
+		}
+		return ExecutableInfo{Format: FormatMachO, Archs: []string{machoArch(mf.Cpu)}}, nil
+	}
+	if ff, err := macho.NewFatFile(f); err == nil {
+		info := ExecutableInfo{Format: FormatMachO}
+		for _, a := range ff.Arches {
+			if a.Type == macho.TypeExec {
+				info.Archs = append(info.Archs, machoArch(a.Cpu))
+			}
+		}
+		if len(info.Archs) == 0 {
+			return ExecutableInfo{}, errors.New("universal mach-o file contains no executable")
+		}
+		return info, nil
+	}
+	return ExecutableInfo{}, errors.New("not an elf, pe or mach-o executable")
+}
+
+func elfArch(f *elf.File) string {
+	le := f.ByteOrder == binary.LittleEndian
+	switch f.Machine {
+	case elf.EM_X86_64:
+		return "amd64"
+	case elf.EM_386:
+		return "386"
+	case elf.EM_AARCH64:
+		return "arm64"
+	case elf.EM_ARM:
+		return "arm"
+	case elf.EM_RISCV:
+		return "riscv64"
+	case elf.EM_LOONGARCH:
+		return "loong64"
+	case elf.EM_S390:
+		return "s390x"
+	case elf.EM_PPC64:
+		if le {
+			return "ppc64le"
+		}
+		return "ppc64"
+	case elf.EM_MIPS:
+		switch {
+		case f.Class == elf.ELFCLASS64 && le:
+			return "mips64le"
+		case f.Class == elf.ELFCLASS64:
+			return "mips64"
+		case le:
+			return "mipsle"
+		}
+		return "mips"
+	}
+	return f.Machine.String()
+}
+
+func peArch(machine uint16) string {
+	switch machine {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return "amd64"
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return "386"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		return "arm64"
+	case pe.IMAGE_FILE_MACHINE_ARMNT:
+		return "arm"
+	}
+	return fmt.Sprintf("machine %#x", machine)
+}
+
+func machoArch(cpu macho.Cpu) string {
+	switch cpu {
+	case macho.CpuAmd64:
+		return "amd64"
+	case macho.Cpu386:
+		return "386"
+	case macho.CpuArm64:
+		return "arm64"
+	case macho.CpuArm:
+		return "arm"
+	}
+	return cpu.String()
+}
+
+// ExecutableFormat returns the format of executables for goos, it is empty if unknown.
+func ExecutableFormat(goos string) string {
+	switch goos {
+	case "windows":
+		return FormatPE
+	case "darwin", "ios":
+		return FormatMachO
+	case "linux", "android", "freebsd", "netbsd", "openbsd", "dragonfly", "solaris", "illumos":
+		return FormatELF
+	}
+	return ""
+}
@@ -5,9 +5,13 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"time"
+
+	"github.com/dhcgn/gh-update/types"
 )
 
 var _ FileOperations = (*FileOperationsImpl)(nil)
@@ -18,51 +22,178 @@ const (
 
 type FileOperations interface {
 	Unzip(zip []byte) (data []byte, err error)
+	UnzipAll(zip []byte) (files []ArchiveFile, err error)
 	CreateNewTempPath(p string) (newPath string, err error)
 	SaveTo(data []byte, path string) error
+	ReadFile(path string) ([]byte, error)
 	MoveRunningExeToBackup(p string) error
 	MoveNewExeToOriginalExe(newPath string, oldPath string) error
 	RemoveExecutable(path string, pid string, try int) error
+	RestoreBackup(p string) error
+	CheckWritable(dir string) error
+	DiskStatus(dir string) (DiskStatus, error)
+	Exists(p string) bool
+	RemoveAll(p string) error
+	ReplaceSymlink(target string, link string) error
+	Readlink(link string) (string, error)
+	ReadDir(dir string) ([]string, error)
+	ExecutableInfo(path string) (ExecutableInfo, error)
+	BuildInfo(path string) (types.BuildInfo, error)
+	Lock(path string) (unlock func() error, err error)
+}
+
+// ArchiveFile is a regular file of a zip archive.
+type ArchiveFile struct {
+	Name string
+	Data []byte
+}
+
+// DiskStatus describes the filesystem a directory lives on.
+type DiskStatus struct {
+	Free     uint64
+	ReadOnly bool
+}
+
+// BackupPath returns the path the running executable p is moved to during an update.
+func BackupPath(p string) string {
+	return p + oldfilesuffix
 }
 
 type FileOperationsImpl struct {
+	Logger *slog.Logger
 }
 
 func (f FileOperationsImpl) RemoveExecutable(path string, pid string, try int) error {
+	log := loggerOrDiscard(f.Logger)
 	if _, err := os.Stat(path + oldfilesuffix); os.IsNotExist(err) {
+		log.Debug("no backup to remove", "path", path+oldfilesuffix)
 		return err
 	}
 
 	err := os.Remove(path + oldfilesuffix)
 	if err == nil {
+		log.Info("removed backup", "path", path+oldfilesuffix, "try", try)
 		return nil
 	}
 
 	if pid != "" {
-		tryKillProcess(pid)
+		if kerr := tryKillProcess(pid); kerr != nil {
+			log.Debug("kill old process failed", "pid", pid, "error", kerr)
+		}
 	}
 
 	if try < 10 {
 		d := time.Duration(try) * 100 * time.Millisecond
+		log.Warn("remove backup failed, retrying", "path", path+oldfilesuffix, "try", try, "delay", d, "error", err)
 		time.Sleep(d)
 		return f.RemoveExecutable(path, pid, try+1)
 	}
+	log.Error("remove backup failed", "path", path+oldfilesuffix, "try", try, "error", err)
 	return err
 }
 
+// RestoreBackup moves the backup of p back to p, the current file at p is removed.
+func (f FileOperationsImpl) RestoreBackup(p string) error {
+	log := loggerOrDiscard(f.Logger)
+	if _, err := os.Stat(p + oldfilesuffix); err != nil {
+		return err
+	}
+
+	rollback := p + ".rollback"
+	if err := os.Rename(p, rollback); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	log.Info("rename backup", "from", p+oldfilesuffix, "to", p)
+	if err := os.Rename(p+oldfilesuffix, p); err != nil {
+		os.Rename(rollback, p)
+		return err
+	}
+	if err := os.Remove(rollback); err != nil && !os.IsNotExist(err) {
+		log.Warn("remove replaced executable failed", "path", rollback, "error", err)
+	}
+	return nil
+}
+
+// CheckWritable creates and removes a probe file in dir.
+func (FileOperationsImpl) CheckWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".gh-update-probe-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
+func (FileOperationsImpl) DiskStatus(dir string) (DiskStatus, error) {
+	return diskStatus(dir)
+}
+
 func (FileOperationsImpl) CreateNewTempPath(p string) (string, error) {
 	return p + ".new.temp", nil
 }
 
-func (FileOperationsImpl) SaveTo(data []byte, path string) error {
+func (f FileOperationsImpl) SaveTo(data []byte, path string) error {
+	loggerOrDiscard(f.Logger).Info("save new executable", "path", path, "bytes", len(data))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
 	return os.WriteFile(path, data, 0755)
 }
 
-func (FileOperationsImpl) MoveRunningExeToBackup(p string) error {
+// ReplaceSymlink points link to target, an existing link is replaced atomically.
+func (f FileOperationsImpl) ReplaceSymlink(target string, link string) error {
+	loggerOrDiscard(f.Logger).Info("point symlink", "link", link, "target", target)
+	tmp := link + ".new.temp"
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (FileOperationsImpl) Readlink(link string) (string, error) {
+	return os.Readlink(link)
+}
+
+// ReadDir returns the names of the entries of dir.
+func (FileOperationsImpl) ReadDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names, nil
+}
+
+func (FileOperationsImpl) Exists(p string) bool {
+	_, err := os.Lstat(p)
+	return err == nil
+}
+
+func (f FileOperationsImpl) RemoveAll(p string) error {
+	loggerOrDiscard(f.Logger).Debug("remove", "path", p)
+	return os.RemoveAll(p)
+}
+
+func (FileOperationsImpl) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (f FileOperationsImpl) MoveRunningExeToBackup(p string) error {
+	loggerOrDiscard(f.Logger).Info("rename running executable to backup", "from", p, "to", p+oldfilesuffix)
 	return os.Rename(p, p+oldfilesuffix)
 }
 
-func (FileOperationsImpl) MoveNewExeToOriginalExe(newPath string, oldPath string) error {
+func (f FileOperationsImpl) MoveNewExeToOriginalExe(newPath string, oldPath string) error {
+	loggerOrDiscard(f.Logger).Info("rename new executable", "from", newPath, "to", oldPath)
 	return os.Rename(newPath, oldPath)
 }
 
@@ -102,3 +233,28 @@ func (f FileOperationsImpl) Unzip(data []byte) (uncompressedFile []byte, err err
 
 	return uncompressedFile, nil
 }
+
+func (f FileOperationsImpl) UnzipAll(data []byte) ([]ArchiveFile, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]ArchiveFile, 0, len(archive.File))
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		r, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, ArchiveFile{Name: file.Name, Data: content})
+	}
+	return files, nil
+}
@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrLocked is returned by Lock if another process holds the lock.
+var ErrLocked = errors.New("locked by another process")
+
+// Lock takes the exclusive advisory lock of the file path without waiting,
+// the lock is held until unlock is called or the process exits.
+func (FileOperationsImpl) Lock(path string) (unlock func() error, err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f.Close, nil
+}
@@ -0,0 +1,16 @@
+package internal
+
+import (
+	"io"
+	"log/slog"
+)
+
+// DiscardLogger drops all records, it is used if no logger is configured.
+var DiscardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func loggerOrDiscard(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return DiscardLogger
+	}
+	return l
+}
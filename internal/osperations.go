@@ -1,40 +1,139 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 )
 
 const (
 	EnvFinishUpdate = "FINISH_UPDATE"
 	EnvKillThisPid  = "KILL_THIS_PID"
+	// EnvPreviousVersion is the version of the application which restarted into the update.
+	EnvPreviousVersion = "PREVIOUS_VERSION"
+	// EnvListeners describes the listening sockets passed to the new process, as fd:network:address separated by ";".
+	EnvListeners = "INHERITED_LISTENERS"
+	// EnvReadyFd is the file descriptor the new process signals its readiness on.
+	EnvReadyFd = "READY_FD"
+	// EnvSystemdExecPid and EnvNotifySocket are set by systemd for the main process of a service.
+	EnvSystemdExecPid = "SYSTEMD_EXEC_PID"
+	EnvNotifySocket   = "NOTIFY_SOCKET"
 )
 
 var _ OsOperations = (*OsOperationsImpl)(nil)
 
 type OsOperations interface {
-	Restart(string) error
+	// Restart starts the process described by opts and returns it without waiting for it.
+	Restart(opts RestartOptions) (*os.Process, error)
+	// Command returns the command line Restart runs for opts.
+	Command(opts RestartOptions) []string
+	// Run executes path with args, waits at most timeout and returns the combined output.
+	Run(path string, args []string, timeout time.Duration) ([]byte, error)
+	// Notify sends state to the systemd notification socket.
+	Notify(state string) error
+	// Exit ends the current process with code.
+	Exit(code int)
 }
 
-type OsOperationsImpl struct{}
+// RestartOptions describes the process started by Restart.
+type RestartOptions struct {
+	// Path of the executable to start.
+	Path string
+	// Args are the arguments without the program name.
+	Args []string
+	// Dir is the working directory, empty for the one of the current process.
+	Dir string
+	// Env is added to the environment of the current process, as key=value.
+	Env []string
+	// ExtraFiles are passed to the new process as file descriptors 3, 4, ...
+	ExtraFiles []*os.File
+}
+
+type OsOperationsImpl struct {
+	Logger *slog.Logger
+}
 
-func (OsOperationsImpl) Restart(path string) error {
-	env := os.Environ()
+func (OsOperationsImpl) Command(opts RestartOptions) []string {
+	return append([]string{opts.Path}, opts.Args...)
+}
+
+func (o OsOperationsImpl) Restart(opts RestartOptions) (*os.Process, error) {
+	env := make([]string, 0)
+	for _, kv := range os.Environ() {
+		// drop the handoff of the restart which started this process
+		key, _, _ := strings.Cut(kv, "=")
+		if key != EnvPreviousVersion && key != EnvListeners && key != EnvReadyFd {
+			env = append(env, kv)
+		}
+	}
 	env = append(env, EnvFinishUpdate+"=1")
 	env = append(env, fmt.Sprintf("%v=%v", EnvKillThisPid, os.Getpid()))
-	cmd := exec.Command(path)
+	env = append(env, opts.Env...)
+	cmd := exec.Command(opts.Path, opts.Args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
 	cmd.Env = env
+	cmd.Dir = opts.Dir
+	cmd.ExtraFiles = opts.ExtraFiles
 
 	err := cmd.Start()
+	if err != nil {
+		loggerOrDiscard(o.Logger).Error("restart failed", "command", cmd.String(), "error", err)
+		return nil, err
+	}
+	loggerOrDiscard(o.Logger).Info("restarted", "command", cmd.String(), "pid", cmd.Process.Pid)
+
+	return cmd.Process, nil
+}
+
+// runWaitDelay is how long Run waits for the output to be closed after the process exited or was killed.
+const runWaitDelay = time.Second
 
+func (OsOperationsImpl) Run(path string, args []string, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	setCancel(cmd)
+	// children which inherited the output must not block after the process exited or was killed
+	cmd.WaitDelay = runWaitDelay
+	out, err := cmd.CombinedOutput()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		// the process itself exited successfully
+		return out, nil
+	}
+	if ctx.Err() != nil {
+		return out, fmt.Errorf("no exit after %s: %w", timeout, ctx.Err())
+	}
+	return out, err
+}
+
+func (o OsOperationsImpl) Notify(state string) error {
+	socket := os.Getenv(EnvNotifySocket)
+	if socket == "" {
+		return errors.New(EnvNotifySocket + " is not set")
+	}
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
 	return err
 }
 
+func (o OsOperationsImpl) Exit(code int) {
+	loggerOrDiscard(o.Logger).Info("exit", "code", code)
+	os.Exit(code)
+}
+
 func tryKillProcess(pid string) error {
 	processe, err := exec.Command("taskkill.exe", "/PID", pid, "/F").Output()
 	if err != nil {
@@ -3,9 +3,11 @@ package internal
 import (
 	"encoding/json"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/dhcgn/gh-update/types"
@@ -15,36 +17,93 @@ var _ WebOperations = (*WebOperationsImpl)(nil)
 
 type WebOperations interface {
 	GetGithubRelease(url string) (*types.GithubReleaseResult, error)
+	GetGithubReleases(url string) (releases []types.GithubReleaseResult, next string, err error)
 	GetAssetReader(url string) (data []byte, err error)
 }
 
 type WebOperationsImpl struct {
 	TestUpdateAssetPath string
+	// Client is used for all requests, http.DefaultClient if nil.
+	Client *http.Client
+	Logger *slog.Logger
+	// Progress is called while an asset is downloaded, total is -1 if unknown.
+	Progress func(done, total int64)
+}
+
+// StatusError is returned for responses without status code 200.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected response status " + e.Status
+}
+
+func (wo WebOperationsImpl) client() *http.Client {
+	if wo.Client != nil {
+		return wo.Client
+	}
+	return http.DefaultClient
 }
 
 func (wo WebOperationsImpl) GetAssetReader(url string) (data []byte, err error) {
+	log := loggerOrDiscard(wo.Logger)
 	if wo.TestUpdateAssetPath != "" {
-		return os.ReadFile(wo.TestUpdateAssetPath)
+		log.Info("read test asset", "path", wo.TestUpdateAssetPath)
+		data, err := os.ReadFile(wo.TestUpdateAssetPath)
+		if err == nil && wo.Progress != nil {
+			wo.Progress(int64(len(data)), int64(len(data)))
+		}
+		return data, err
 	}
 
-	resp, err := http.Get(url)
+	log.Info("download asset", "url", url)
+	resp, err := wo.client().Get(url)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	log.Debug("asset response", "url", url, "status", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
 
-	data, err = io.ReadAll(resp.Body)
+	var body io.Reader = resp.Body
+	if wo.Progress != nil {
+		body = &progressReader{r: resp.Body, total: resp.ContentLength, fn: wo.Progress}
+	}
+	data, err = io.ReadAll(body)
 	if err != nil {
 		return nil, err
 	}
+	log.Info("downloaded asset", "url", url, "status", resp.StatusCode, "bytes", len(data))
 	return data, nil
 }
 
+type progressReader struct {
+	r     io.Reader
+	done  int64
+	total int64
+	fn    func(done, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.done += int64(n)
+		p.fn(p.done, p.total)
+	}
+	return n, err
+}
+
 func (wo WebOperationsImpl) getTestData(url string) (*types.GithubReleaseResult, error) {
 	base := filepath.Base(wo.TestUpdateAssetPath)
 	return &types.GithubReleaseResult{
 
 			TagName: "v0.0.2",
+			Name:    "Test release v0.0.2",
+			Body:    "Test release created from " + wo.TestUpdateAssetPath,
 			Assets: []types.Assets{
 				{
 					Name:               base,
@@ -61,9 +120,33 @@ func (wo WebOperationsImpl) GetGithubRelease(url string) (*types.GithubReleaseRe
 		return wo.getTestData(url)
 	}
 
+	ghr := &types.GithubReleaseResult{}
+	if _, err := wo.getGithubJSON(url, ghr); err != nil {
+		return nil, err
+	}
+	return ghr, nil
+}
+
+// GetGithubReleases returns one page of the releases list url, next is the url of the next page or empty.
+func (wo WebOperationsImpl) GetGithubReleases(url string) (releases []types.GithubReleaseResult, next string, err error) {
+	if wo.TestUpdateAssetPath != "" {
+		r, err := wo.getTestData(url)
+		if err != nil {
+			return nil, "", err
+		}
+		return []types.GithubReleaseResult{*r}, "", nil
+	}
+
+	header, err := wo.getGithubJSON(url, &releases)
+	if err != nil {
+		return nil, "", err
+	}
+	return releases, nextLink(header.Get("Link")), nil
+}
+
+func (wo WebOperationsImpl) getGithubJSON(url string, v any) (http.Header, error) {
 	method := "GET"
 
-	client := &http.Client{}
 	req, err := http.NewRequest(method, url, nil)
 
 	if err != nil {
@@ -75,20 +158,42 @@ func (wo WebOperationsImpl) GetGithubRelease(url string) (*types.GithubReleaseRe
 		req.Header.Add("Authorization", "Bearer "+os.Getenv("GITHUB_TOKEN"))
 	}
 
-	res, err := client.Do(req)
+	log := loggerOrDiscard(wo.Logger)
+	log.Info("request release", "url", url)
+	res, err := wo.client().Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
+	log.Info("release response", "url", url, "status", res.StatusCode)
+	if res.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: res.StatusCode, Status: res.Status}
+	}
 
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
 		return nil, err
 	}
-	ghr := &types.GithubReleaseResult{}
-	err = json.Unmarshal(body, &ghr)
+	err = json.Unmarshal(body, v)
 	if err != nil {
 		return nil, err
 	}
-	return ghr, nil
+	return res.Header, nil
+}
+
+// nextLink returns the url with rel="next" of a GitHub Link header, e.g.
+// <https://api.github.com/repositories/1/releases?page=2>; rel="next", <...>; rel="last"
+func nextLink(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		segments := strings.Split(part, ";")
+		if len(segments) < 2 {
+			continue
+		}
+		for _, param := range segments[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(segments[0]), "<>")
+			}
+		}
+	}
+	return ""
 }
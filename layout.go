@@ -0,0 +1,190 @@
+package update
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	layoutVersions = "versions"
+	layoutCurrent  = "current"
+	layoutPrevious = "previous"
+)
+
+// WithVersionedLayout installs every release into root/versions/<version>/ and points the symlink root/current to it.
+// This is meant for applications which are a directory tree instead of a single executable.
+// An update extracts the full zip asset into a new version directory and flips the symlink atomically,
+// Rollback flips it back to the previous version and CleanUpAfterUpdate removes all other versions.
+// The application is restarted as root/current/<name>, where name is the executable member
+// of the archive (see WithExecutableMember) or the base name of the running executable.
+func WithVersionedLayout(root string) Option {
+	return func(u *Updater) {
+		u.layoutRoot = root
+	}
+}
+
+// layoutExecutable returns the path of the executable below a version directory.
+func (u *Updater) layoutExecutable(exepath string) string {
+	if u.exeMember != "" {
+		return filepath.FromSlash(u.exeMember)
+	}
+	return filepath.Base(exepath)
+}
+
+// restartPath returns the path the application is restarted with after an update.
+func (u *Updater) restartPath(exepath string) string {
+	if u.layoutRoot == "" {
+		return exepath
+	}
+	return filepath.Join(u.layoutRoot, layoutCurrent, u.layoutExecutable(exepath))
+}
+
+// applyVersioned installs latest into a new version directory and flips the current symlink to it.
+func (u *Updater) applyVersioned(latest LatestRelease, exepath string) (*UpdateResult, error) {
+	if !filepath.IsLocal(latest.Version) || strings.ContainsAny(latest.Version, `/\`) {
+		return nil, fmt.Errorf("invalid version %q for a version directory", latest.Version)
+	}
+	versionDir := filepath.Join(u.layoutRoot, layoutVersions, latest.Version)
+	current := filepath.Join(u.layoutRoot, layoutCurrent)
+	target := path.Join(layoutVersions, latest.Version)
+
+	// replacing the version directory would delete the running tree
+	if previous, err := u.fops.Readlink(current); err == nil && path.Clean(filepath.ToSlash(previous)) == target {
+		return nil, fmt.Errorf("%w: %s is already the current version", ErrorNoNewVersionFound, latest.Version)
+	}
+
+	staged, err := u.fops.CreateNewTempPath(versionDir)
+	if err != nil {
+		return nil, &SwapError{Stage: StageSave, From: versionDir, Err: err}
+	}
+	if err := u.fops.RemoveAll(staged); err != nil {
+		return nil, &SwapError{Stage: StageSave, To: staged, Err: err}
+	}
+
+	if _, err := u.downloadVersion(latest, exepath, staged); err != nil {
+		_ = u.fops.RemoveAll(staged)
+		return nil, err
+	}
+
+	bi, err := u.validate(latest, exepath, filepath.Join(staged, u.layoutExecutable(exepath)))
+	if err != nil {
+		_ = u.fops.RemoveAll(staged)
+		return nil, err
+	}
+
+	if err := u.preUpdate(latest); err != nil {
+		_ = u.fops.RemoveAll(staged)
+		return nil, err
+	}
+
+	if u.hooks.BeforeUpdate != nil {
+		u.hooks.BeforeUpdate(latest)
+	}
+
+	if u.fops.Exists(versionDir) {
+		if err := u.fops.RemoveAll(versionDir); err != nil {
+			return nil, &SwapError{Stage: StageReplace, To: versionDir, Err: err}
+		}
+	}
+	if err := u.fops.MoveNewExeToOriginalExe(staged, versionDir); err != nil {
+		return nil, &SwapError{Stage: StageReplace, From: staged, To: versionDir, Err: err}
+	}
+
+	previous, _ := u.fops.Readlink(current)
+	if err := u.fops.ReplaceSymlink(target, current); err != nil {
+		return nil, &SwapError{Stage: StageReplace, From: target, To: current, Err: err}
+	}
+	if previous != "" && previous != target {
+		if err := u.fops.ReplaceSymlink(previous, filepath.Join(u.layoutRoot, layoutPrevious)); err != nil {
+			u.log.Warn("point previous symlink failed", "target", previous, "error", err)
+		}
+	}
+
+	if u.hooks.AfterUpdate != nil {
+		u.hooks.AfterUpdate(latest)
+	}
+	return &UpdateResult{Release: latest, ExecutablePath: u.restartPath(exepath), BuildInfo: bi}, nil
+}
+
+// downloadVersion downloads latest and extracts it into dir, which becomes a version directory,
+// and returns the downloaded asset.
+func (u *Updater) downloadVersion(latest LatestRelease, exepath string, dir string) ([]byte, error) {
+	assetData, err := u.webop.GetAssetReader(latest.Url)
+	if err != nil {
+		return nil, newDownloadError(latest.Url, err)
+	}
+
+	if !strings.HasSuffix(latest.Name, ".zip") {
+		p := filepath.Join(dir, u.layoutExecutable(exepath))
+		if err := u.fops.SaveTo(assetData, p); err != nil {
+			return nil, &SwapError{Stage: StageSave, To: p, Err: err}
+		}
+		return assetData, nil
+	}
+
+	files, err := u.fops.UnzipAll(assetData)
+	if err != nil {
+		return nil, fmt.Errorf("unzip %s: %w", latest.Name, err)
+	}
+	for _, f := range files {
+		name := filepath.FromSlash(f.Name)
+		if !filepath.IsLocal(name) {
+			return nil, fmt.Errorf("%s: %s escapes the version directory", latest.Name, f.Name)
+		}
+		if err := u.fops.SaveTo(f.Data, filepath.Join(dir, name)); err != nil {
+			return nil, &SwapError{Stage: StageSave, To: filepath.Join(dir, name), Err: err}
+		}
+	}
+	u.log.Debug("extracted asset", "asset", latest.Name, "files", len(files), "path", dir)
+	return assetData, nil
+}
+
+// rollbackVersioned flips the current symlink back to the previous version.
+func (u *Updater) rollbackVersioned() error {
+	current := filepath.Join(u.layoutRoot, layoutCurrent)
+	previousLink := filepath.Join(u.layoutRoot, layoutPrevious)
+
+	previous, err := u.fops.Readlink(previousLink)
+	if err != nil {
+		return &SwapError{Stage: StageRollback, From: previousLink, To: current, Err: err}
+	}
+	target, err := u.fops.Readlink(current)
+	if err != nil {
+		return &SwapError{Stage: StageRollback, From: previous, To: current, Err: err}
+	}
+
+	if err := u.fops.ReplaceSymlink(previous, current); err != nil {
+		return &SwapError{Stage: StageRollback, From: previous, To: current, Err: err}
+	}
+	if err := u.fops.ReplaceSymlink(target, previousLink); err != nil {
+		u.log.Warn("point previous symlink failed", "target", target, "error", err)
+	}
+	return nil
+}
+
+// cleanUpVersioned removes all version directories except the current and the previous one.
+func (u *Updater) cleanUpVersioned() error {
+	keep := make(map[string]bool)
+	for _, link := range []string{layoutCurrent, layoutPrevious} {
+		if target, err := u.fops.Readlink(filepath.Join(u.layoutRoot, link)); err == nil {
+			keep[path.Base(filepath.ToSlash(target))] = true
+		}
+	}
+
+	versions := filepath.Join(u.layoutRoot, layoutVersions)
+	names, err := u.fops.ReadDir(versions)
+	if err != nil {
+		return &SwapError{Stage: StageCleanUp, From: versions, Err: err}
+	}
+	for _, name := range names {
+		if keep[name] {
+			continue
+		}
+		if err := u.fops.RemoveAll(filepath.Join(versions, name)); err != nil {
+			return &SwapError{Stage: StageCleanUp, From: filepath.Join(versions, name), Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package update
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestVersionedLayout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "versions", "v1.0.0", "lib"), 0755)
+	os.WriteFile(filepath.Join(root, "versions", "v1.0.0", "myapp"), []byte("v1 exe"), 0755)
+	os.WriteFile(filepath.Join(root, "versions", "v1.0.0", "lib", "data.txt"), []byte("v1 data"), 0644)
+	os.Symlink("versions/v1.0.0", filepath.Join(root, "current"))
+
+	apply := func(version string) error {
+		t.Helper()
+		asset := zipFiles(t, map[string]string{"myapp": version + " exe", "lib/data.txt": version + " data"})
+		u, err := New(
+			WithRepository("owner/repo"),
+			WithVersion("v1.0.0"),
+			WithSource(&AssetWebOperationsMock{assets: map[string][]byte{"https://asset": asset}}),
+			WithVersionedLayout(root),
+			WithExecutableCheck(false),
+			WithHooks(Hooks{BeforeUpdate: func(latest LatestRelease) {
+				if _, err := os.Stat(filepath.Join(root, "versions", latest.Version)); err == nil {
+					t.Errorf("BeforeUpdate called after the version directory was installed")
+				}
+			}}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u.osps = &OsOperationsMock{}
+
+		latest := LatestRelease{Name: "myapp-linux-amd64.zip", Url: "https://asset", Version: version}
+		return u.Apply(latest, filepath.Join(root, "current", "myapp"))
+	}
+	install := func(version string) {
+		t.Helper()
+		if err := apply(version); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+	}
+	current := func() string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(root, "current", "lib", "data.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	install("v2.0.0")
+	if got := current(); got != "v2.0.0 data" {
+		t.Fatalf("Apply() current = %q, want v2.0.0 data", got)
+	}
+	if target, _ := os.Readlink(filepath.Join(root, "previous")); target != "versions/v1.0.0" {
+		t.Errorf("Apply() previous = %q, want versions/v1.0.0", target)
+	}
+
+	install("v3.0.0")
+	if err := apply("v3.0.0"); !errors.Is(err, ErrorNoNewVersionFound) {
+		t.Errorf("Apply() of the current version error = %v, want ErrorNoNewVersionFound", err)
+	}
+	if got := current(); got != "v3.0.0 data" {
+		t.Errorf("Apply() of the current version changed current to %q", got)
+	}
+	u, _ := New(WithRepository("owner/repo"), WithVersion("v3.0.0"), WithVersionedLayout(root))
+	if got := u.restartPath("/proc/self/exe"); got != filepath.Join(root, "current", "exe") {
+		t.Errorf("restartPath() = %v", got)
+	}
+
+	if err := u.Rollback(""); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if got := current(); got != "v2.0.0 data" {
+		t.Errorf("Rollback() current = %q, want v2.0.0 data", got)
+	}
+
+	if err := u.CleanUpAfterUpdate("", ""); err != nil {
+		t.Fatalf("CleanUpAfterUpdate() error = %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(root, "versions"))
+	if len(entries) != 2 {
+		t.Errorf("CleanUpAfterUpdate() left %d versions, want current and previous", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(root, "versions", "v1.0.0")); !os.IsNotExist(err) {
+		t.Errorf("CleanUpAfterUpdate() kept v1.0.0")
+	}
+}
+
+func TestVersionedLayoutNotApplied(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		want any
+	}{
+		{
+			name: "veto",
+			opts: []Option{WithExecutableCheck(false), WithHooks(Hooks{PreUpdate: func(latest LatestRelease) error { return Veto("busy") }})},
+			want: new(*VetoError),
+		},
+		{
+			name: "invalid executable",
+			opts: []Option{WithExecutableCheck(true)},
+			want: new(*ValidationError),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, v := range []string{"v1.0.0", "v2.0.0"} {
+				os.MkdirAll(filepath.Join(root, "versions", v), 0755)
+				os.WriteFile(filepath.Join(root, "versions", v, "myapp"), []byte(v+" exe"), 0755)
+			}
+			os.Symlink("versions/v1.0.0", filepath.Join(root, "current"))
+
+			asset := zipFiles(t, map[string]string{"myapp": "new exe"})
+			u, err := New(append([]Option{
+				WithRepository("owner/repo"),
+				WithVersion("v1.0.0"),
+				WithSource(&AssetWebOperationsMock{assets: map[string][]byte{"https://asset": asset}}),
+				WithVersionedLayout(root),
+			}, tt.opts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			latest := LatestRelease{Name: "myapp-linux-amd64.zip", Url: "https://asset", Version: "v2.0.0"}
+			if err := u.Apply(latest, filepath.Join(root, "current", "myapp")); !errors.As(err, tt.want) {
+				t.Fatalf("Apply() error = %v, want %T", err, tt.want)
+			}
+			if got := readTree(t, filepath.Join(root, "versions")); len(got) != 2 || got["v2.0.0/myapp"] != "v2.0.0 exe" {
+				t.Errorf("Apply() changed the version directories: %v", got)
+			}
+			if target, _ := os.Readlink(filepath.Join(root, "current")); target != "versions/v1.0.0" {
+				t.Errorf("Apply() current = %q, want versions/v1.0.0", target)
+			}
+		})
+	}
+}
+
+func TestVersionedLayoutDryRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "versions", "v1.0.0"), 0755)
+	os.WriteFile(filepath.Join(root, "versions", "v1.0.0", "myapp"), []byte("v1 exe"), 0755)
+	os.Symlink("versions/v1.0.0", filepath.Join(root, "current"))
+
+	asset := zipFiles(t, map[string]string{"myapp": "v2 exe", "lib/data.txt": "v2 data"})
+	u, err := New(
+		WithRepository("owner/repo"),
+		WithVersion("v1.0.0"),
+		WithSource(&AssetWebOperationsMock{assets: map[string][]byte{"https://asset": asset}}),
+		WithVersionedLayout(root),
+		WithExecutableCheck(false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	latest := LatestRelease{Name: "myapp-linux-amd64.zip", Url: "https://asset", Version: "v2.0.0"}
+	plan, err := u.Plan(latest, filepath.Join(root, "current", "myapp"), true)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	defer os.RemoveAll(plan.DownloadedPath)
+
+	if got := readTree(t, plan.DownloadedPath); len(got) != 2 || got["myapp"] != "v2 exe" || got["lib/data.txt"] != "v2 data" {
+		t.Errorf("Plan() extracted %v", got)
+	}
+	if plan.Bytes != len(asset) || plan.Validation != nil {
+		t.Errorf("Plan() Bytes = %d, Validation = %v, want %d, nil", plan.Bytes, plan.Validation, len(asset))
+	}
+	if got := readTree(t, filepath.Join(root, "versions")); len(got) != 1 {
+		t.Errorf("Plan() changed the version directories: %v", got)
+	}
+}
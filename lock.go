@@ -0,0 +1,69 @@
+package update
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/dhcgn/gh-update/internal"
+)
+
+// ErrorUpdateInProgress is returned if another process updates the same executable, see WithLock.
+var ErrorUpdateInProgress = errors.New("another update is in progress")
+
+// lockRetryInterval is the interval in which a held lock is tried again.
+const lockRetryInterval = 100 * time.Millisecond
+
+// WithLock sets the lock file which prevents several processes from updating the same executable at once
+// and how long to wait for an update of another process to finish before ErrorUpdateInProgress is returned.
+// An empty path keeps the default, the executable path with the suffix ".lock" or update.lock in the root
+// of WithVersionedLayout, and the default wait is zero.
+func WithLock(path string, wait time.Duration) Option {
+	return func(u *Updater) {
+		u.lockFile = path
+		u.lockWait = wait
+	}
+}
+
+// lockPath returns the lock file for updates of exepath.
+func (u *Updater) lockPath(exepath string) string {
+	if u.lockFile != "" {
+		return u.lockFile
+	}
+	if u.layoutRoot != "" {
+		return filepath.Join(u.layoutRoot, "update.lock")
+	}
+	return exepath + ".lock"
+}
+
+// lock takes the update lock of exepath, waiting at most for the configured wait.
+// unlock must be called when the update is finished.
+func (u *Updater) lock(exepath string) (unlock func(), err error) {
+	path := u.lockPath(exepath)
+	deadline := time.Now().Add(u.lockWait)
+	for {
+		release, err := u.fops.Lock(path)
+		switch {
+		case err == nil:
+			u.log.Debug("update lock taken", "path", path)
+			return func() {
+				if err := release(); err != nil {
+					u.log.Warn("release update lock failed", "path", path, "error", err)
+				}
+			}, nil
+		case errors.Is(err, errors.ErrUnsupported):
+			u.log.Warn("update lock not supported", "path", path)
+			return func() {}, nil
+		case !errors.Is(err, internal.ErrLocked):
+			return nil, fmt.Errorf("update lock %s: %w", path, err)
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, fmt.Errorf("%w: %s is locked", ErrorUpdateInProgress, path)
+		}
+		u.log.Info("waiting for update lock", "path", path)
+		time.Sleep(min(remaining, lockRetryInterval))
+	}
+}
@@ -0,0 +1,124 @@
+package update
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dhcgn/gh-update/internal"
+)
+
+type LockFileOperationsMock struct {
+	FileOperationsMock
+}
+
+// Lock implements internal.FileOperations
+func (*LockFileOperationsMock) Lock(path string) (unlock func() error, err error) {
+	return internal.FileOperationsImpl{}.Lock(path)
+}
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "update.lock")
+	newUpdater := func(wait time.Duration) *Updater {
+		u, err := New(
+			WithRepository("owner/repo"),
+			WithVersion("v0.0.2"),
+			WithAssetFilter("^myapp-.*windows.*zip$"),
+			WithSource(&WebOperationsMock{}),
+			WithLock(path, wait),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u.fops = &LockFileOperationsMock{}
+		u.osps = &OsOperationsMock{}
+		return u
+	}
+
+	// another process updates
+	release, err := internal.FileOperationsImpl{}.Lock(path)
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+
+	err = newUpdater(0).SelfUpdateWithLatestAndRestart(`C:\myapp.exe`)
+	if !errors.Is(err, ErrorUpdateInProgress) {
+		t.Fatalf("SelfUpdateWithLatestAndRestart() error = %v, want ErrorUpdateInProgress", err)
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		release()
+	}()
+	if err := newUpdater(5 * time.Second).SelfUpdateWithLatestAndRestart(`C:\myapp.exe`); err != nil {
+		t.Fatalf("SelfUpdateWithLatestAndRestart() waiting for the lock error = %v", err)
+	}
+
+	// the lock is released after the update
+	if err := newUpdater(0).Rollback(`C:\myapp.exe`); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+}
+
+func TestLockPath(t *testing.T) {
+	exe := filepath.Join("opt", "myapp", "myapp")
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{name: "next to executable", want: exe + ".lock"},
+		{name: "versioned layout", opts: []Option{WithVersionedLayout("/opt/myapp")}, want: filepath.Join("/opt/myapp", "update.lock")},
+		{name: "lock file", opts: []Option{WithLock("/run/myapp.lock", 0)}, want: "/run/myapp.lock"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := New(append([]Option{WithRepository("owner/repo"), WithVersion("v1.0.0")}, tt.opts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := u.lockPath(exe); got != tt.want {
+				t.Errorf("lockPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyAfterWaitingForLock(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "myapp.exe")
+	os.WriteFile(exe, []byte("old exe"), 0755)
+
+	asset := zipFiles(t, map[string]string{"myapp.exe": "new exe"})
+	latest := LatestRelease{Name: "myapp-v0.0.3-windows-amd64.zip", Url: "https://asset", Version: "v0.0.3"}
+	newUpdater := func() *Updater {
+		u, err := New(
+			WithRepository("owner/repo"),
+			WithVersion("v0.0.2"),
+			WithSource(&AssetWebOperationsMock{assets: map[string][]byte{"https://asset": asset}}),
+			WithExecutableCheck(false),
+			WithLock("", 5*time.Second),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	// both processes found v0.0.3, the second one waited for the lock
+	if err := newUpdater().Apply(latest, exe); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if err := newUpdater().Apply(latest, exe); !errors.Is(err, ErrorNoNewVersionFound) {
+		t.Fatalf("second Apply() error = %v, want ErrorNoNewVersionFound", err)
+	}
+
+	if got, _ := os.ReadFile(exe); string(got) != "new exe" {
+		t.Errorf("executable = %q, want new exe", got)
+	}
+	if got, _ := os.ReadFile(exe + ".old"); string(got) != "old exe" {
+		t.Errorf("backup = %q, want old exe", got)
+	}
+}
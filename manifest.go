@@ -0,0 +1,66 @@
+package update
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/dhcgn/gh-update/types"
+)
+
+// DefaultManifestName is the name of the optional release asset with update policies, see Manifest.
+const DefaultManifestName = "update-manifest.json"
+
+// Manifest is an optional JSON asset of a release which controls how the update is offered, e.g.
+//
+//	{"minimum_version": "v1.2.0", "recommended": true, "rollout_percentage": 20}
+type Manifest struct {
+	// MinimumVersion is the lowest supported version, older versions must update.
+	MinimumVersion string `json:"minimum_version,omitempty"`
+	// Recommended marks the update as recommended instead of optional.
+	Recommended bool `json:"recommended,omitempty"`
+	// RolloutPercentage limits the release to a share of the installations from 0 to 100,
+	// all installations get the release if it is not set. Mandatory updates ignore it.
+	RolloutPercentage *int `json:"rollout_percentage,omitempty"`
+}
+
+// Urgency tells the caller how strongly an update should be installed.
+type Urgency string
+
+const (
+	UrgencyOptional    Urgency = "optional"
+	UrgencyRecommended Urgency = "recommended"
+	// UrgencyMandatory is set if the current version is below the minimum supported version.
+	UrgencyMandatory Urgency = "mandatory"
+)
+
+// Urgency returns the urgency of the update from version current.
+func (m Manifest) Urgency(current string) Urgency {
+	switch {
+	case m.MinimumVersion != "" && CompareVersions(current, m.MinimumVersion) < 0:
+		return UrgencyMandatory
+	case m.Recommended:
+		return UrgencyRecommended
+	}
+	return UrgencyOptional
+}
+
+// manifest downloads the manifest asset of release, an empty Manifest is returned if the release has none.
+func (u *Updater) manifest(release *types.GithubReleaseResult) (Manifest, error) {
+	for _, asset := range release.Assets {
+		if asset.Name != u.manifestName {
+			continue
+		}
+
+		data, err := u.webop.GetAssetReader(asset.BrowserDownloadURL)
+		if err != nil {
+			return Manifest{}, newDownloadError(asset.BrowserDownloadURL, err)
+		}
+		var m Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return Manifest{}, fmt.Errorf("invalid manifest %s in version %v: %w", asset.Name, release.TagName, err)
+		}
+		u.log.Info("manifest", "asset", asset.Name, "minimum_version", m.MinimumVersion, "recommended", m.Recommended)
+		return m, nil
+	}
+	return Manifest{}, nil
+}
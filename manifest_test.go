@@ -0,0 +1,72 @@
+package update
+
+import (
+	"testing"
+
+	"github.com/dhcgn/gh-update/types"
+)
+
+type ManifestWebOperationsMock struct {
+	WebOperationsMock
+	manifest string
+}
+
+// GetGithubRelease implements internal.WebOperations
+func (m *ManifestWebOperationsMock) GetGithubRelease(url string) (*types.GithubReleaseResult, error) {
+	r, _ := m.WebOperationsMock.GetGithubRelease(url)
+	r.Assets = append(r.Assets, types.Assets{Name: DefaultManifestName, BrowserDownloadURL: "https://manifest"})
+	return r, nil
+}
+
+// GetAssetReader implements internal.WebOperations
+func (m *ManifestWebOperationsMock) GetAssetReader(url string) (data []byte, err error) {
+	return []byte(m.manifest), nil
+}
+
+func TestManifestUrgency(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest Manifest
+		current  string
+		want     Urgency
+	}{
+		{name: "no manifest", current: "v1.0.0", want: UrgencyOptional},
+		{name: "below minimum", manifest: Manifest{MinimumVersion: "v1.2.0"}, current: "v1.1.9", want: UrgencyMandatory},
+		{name: "at minimum", manifest: Manifest{MinimumVersion: "v1.2.0"}, current: "v1.2.0", want: UrgencyOptional},
+		{name: "recommended", manifest: Manifest{MinimumVersion: "v1.2.0", Recommended: true}, current: "v1.5.0", want: UrgencyRecommended},
+		{name: "mandatory wins over recommended", manifest: Manifest{MinimumVersion: "v1.2.0", Recommended: true}, current: "v1.0.0", want: UrgencyMandatory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.manifest.Urgency(tt.current); got != tt.want {
+				t.Errorf("Manifest.Urgency() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetLatestVersionManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		version  string
+		want     Urgency
+		wantErr  bool
+	}{
+		{name: "mandatory", manifest: `{"minimum_version": "v1.0.0"}`, version: "v0.9.0", want: UrgencyMandatory},
+		{name: "recommended", manifest: `{"minimum_version": "v1.0.0", "recommended": true}`, version: "v1.1.0", want: UrgencyRecommended},
+		{name: "invalid manifest", manifest: `not json`, version: "v1.1.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webop = &ManifestWebOperationsMock{manifest: tt.manifest}
+			got, err := GetLatestVersion("owner/repo", tt.version, "^myapp-.*windows.*zip$")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetLatestVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Urgency != tt.want {
+				t.Errorf("GetLatestVersion() Urgency = %v, want %v", got.Urgency, tt.want)
+			}
+		})
+	}
+}
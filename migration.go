@@ -0,0 +1,160 @@
+package update
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/dhcgn/gh-update/internal"
+)
+
+// Migration migrates the data of the application, e.g. config files or databases,
+// from the version before the update to the version the migration is registered for.
+type Migration func(from, to string) error
+
+type migration struct {
+	version string
+	run     Migration
+}
+
+// migrationState is stored between the starts of the application.
+type migrationState struct {
+	// Version is the last version whose migrations have all run.
+	Version string `json:"version"`
+	// Done are the versions whose migrations have run.
+	Done []string `json:"done"`
+}
+
+// WithMigration registers fn to run on the first start of version or of a later version
+// if it is updated from a version before version, see RunMigrations.
+// Several migrations of the same version run in the order they are registered.
+func WithMigration(version string, fn Migration) Option {
+	return func(u *Updater) {
+		u.migrations = append(u.migrations, migration{version: version, run: fn})
+	}
+}
+
+// WithMigrationState sets the file which records the migrations that have run,
+// the default is a file per repository in the user config directory.
+func WithMigrationState(path string) Option {
+	return func(u *Updater) {
+		u.migrationState = path
+	}
+}
+
+// GetPreviousVersion returns the version of the application which restarted into the update,
+// it is empty if the application was not restarted by an update.
+func GetPreviousVersion() string {
+	return os.Getenv(internal.EnvPreviousVersion)
+}
+
+// RunMigrations runs the migrations registered with WithMigration which are newer than the previous version
+// and not newer than the current version, in version order, and returns the versions which ran.
+// Call it on every start, the previous version is taken from GetPreviousVersion or else from the recorded state,
+// so migrations interrupted by a failure run again on the next start.
+// On the first start without any record no migration runs and the current version is recorded.
+func (u *Updater) RunMigrations() ([]string, error) {
+	if u.version == "" {
+		return nil, errors.New("current version is empty")
+	}
+
+	path, err := u.migrationStatePath()
+	if err != nil {
+		return nil, err
+	}
+	state, err := readMigrationState(path)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := GetPreviousVersion()
+	if previous == "" {
+		previous = state.Version
+	}
+	if previous == "" {
+		state.Version = u.version
+		return nil, writeMigrationState(path, state)
+	}
+	if state.Version != previous {
+		// keep the previous version for a retry if a migration fails
+		state.Version = previous
+		if err := writeMigrationState(path, state); err != nil {
+			return nil, err
+		}
+	}
+
+	pending := make([]migration, 0, len(u.migrations))
+	for _, m := range u.migrations {
+		if CompareVersions(m.version, previous) > 0 && CompareVersions(m.version, u.version) <= 0 && !slices.Contains(state.Done, m.version) {
+			pending = append(pending, m)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		return CompareVersions(pending[i].version, pending[j].version) < 0
+	})
+
+	var ran []string
+	for i, m := range pending {
+		u.log.Info("migration", "from", previous, "to", m.version)
+		if err := m.run(previous, m.version); err != nil {
+			return ran, &MigrationError{Version: m.version, Err: err}
+		}
+		if i+1 < len(pending) && pending[i+1].version == m.version {
+			continue
+		}
+		ran = append(ran, m.version)
+		state.Done = append(state.Done, m.version)
+		if err := writeMigrationState(path, state); err != nil {
+			return ran, err
+		}
+	}
+
+	state.Version = u.version
+	return ran, writeMigrationState(path, state)
+}
+
+func (u *Updater) migrationStatePath() (string, error) {
+	if u.migrationState != "" {
+		return u.migrationState, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	name := strings.ReplaceAll(u.repo, "/", "_")
+	if name == "" {
+		name = "default"
+	}
+	return filepath.Join(dir, "gh-update", name, "migrations.json"), nil
+}
+
+func readMigrationState(path string) (migrationState, error) {
+	var state migrationState
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(b, &state); err != nil {
+		return state, err
+	}
+	return state, nil
+}
+
+func writeMigrationState(path string, state migrationState) error {
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
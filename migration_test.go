@@ -0,0 +1,124 @@
+package update
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dhcgn/gh-update/internal"
+)
+
+func TestRunMigrations(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "migrations.json")
+
+	var calls []string
+	record := func(from, to string) error {
+		calls = append(calls, from+"->"+to)
+		return nil
+	}
+	newUpdater := func(version string) *Updater {
+		u, err := New(
+			WithRepository("owner/repo"),
+			WithVersion(version),
+			WithMigrationState(state),
+			WithMigration("v1.3.0", record),
+			WithMigration("v1.1.0", record),
+			WithMigration("v1.2.0", record),
+			WithMigration("v2.0.0", record),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	// the first start only records the version
+	ran, err := newUpdater("v1.0.0").RunMigrations()
+	if err != nil || len(ran) != 0 {
+		t.Fatalf("RunMigrations() = %v, %v, want nothing to run", ran, err)
+	}
+
+	t.Setenv(internal.EnvPreviousVersion, "v1.0.0")
+	ran, err = newUpdater("v1.2.0").RunMigrations()
+	if err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+	if want := []string{"v1.1.0", "v1.2.0"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("RunMigrations() = %v, want %v", ran, want)
+	}
+
+	// a restart without the update environment runs nothing again
+	t.Setenv(internal.EnvPreviousVersion, "")
+	ran, err = newUpdater("v1.2.0").RunMigrations()
+	if err != nil || len(ran) != 0 {
+		t.Fatalf("RunMigrations() = %v, %v, want nothing to run", ran, err)
+	}
+
+	// the previous version is taken from the state
+	ran, err = newUpdater("v1.3.0").RunMigrations()
+	if err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+	if want := []string{"v1.3.0"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("RunMigrations() = %v, want %v", ran, want)
+	}
+
+	want := []string{"v1.0.0->v1.1.0", "v1.0.0->v1.2.0", "v1.2.0->v1.3.0"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("migrations called %v, want %v", calls, want)
+	}
+}
+
+func TestRunMigrationsFailure(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "migrations.json")
+	t.Setenv(internal.EnvPreviousVersion, "v1.0.0")
+
+	fail := true
+	var calls []string
+	newUpdater := func() *Updater {
+		u, err := New(
+			WithRepository("owner/repo"),
+			WithVersion("v1.2.0"),
+			WithMigrationState(state),
+			WithMigration("v1.1.0", func(from, to string) error {
+				calls = append(calls, to)
+				return nil
+			}),
+			WithMigration("v1.2.0", func(from, to string) error {
+				calls = append(calls, to)
+				if fail {
+					return errors.New("database locked")
+				}
+				return nil
+			}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	ran, err := newUpdater().RunMigrations()
+	var merr *MigrationError
+	if !errors.As(err, &merr) || merr.Version != "v1.2.0" {
+		t.Fatalf("RunMigrations() error = %v, want MigrationError for v1.2.0", err)
+	}
+	if want := []string{"v1.1.0"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("RunMigrations() = %v, want %v", ran, want)
+	}
+
+	// the failed migration runs again on the next start, the successful one not
+	fail = false
+	t.Setenv(internal.EnvPreviousVersion, "")
+	ran, err = newUpdater().RunMigrations()
+	if err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+	if want := []string{"v1.2.0"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("RunMigrations() = %v, want %v", ran, want)
+	}
+	if want := []string{"v1.1.0", "v1.2.0", "v1.2.0"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("migrations called %v, want %v", calls, want)
+	}
+}
@@ -0,0 +1,80 @@
+package update
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// PreflightReport is the result of Preflight and describes whether the
+// running executable can be replaced by an update.
+type PreflightReport struct {
+	ExecutablePath string
+	Dir            string
+	Writable       bool
+	ReadOnlyMount  bool
+	// FreeBytes is zero when the free space could not be determined on this platform.
+	FreeBytes     uint64
+	RequiredBytes uint64
+	EnoughSpace   bool
+	// Problems contains a human readable message for every failed check.
+	Problems []string
+}
+
+// OK reports whether all pre-flight checks passed.
+func (r PreflightReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Preflight checks if the update described by latest can be installed over runningexepath
+// before anything is downloaded. It verifies that the directory of the executable is writable,
+// not on a read-only mount and has enough free space for the asset plus the backup of the running executable.
+// A failed check is reported in PreflightReport.Problems, the returned error is only set if the checks could not run.
+func Preflight(latest LatestRelease, runningexepath string) (PreflightReport, error) {
+	return defaultUpdater("", "", AutoAssetFilter).Preflight(latest, runningexepath)
+}
+
+// Preflight checks if the update described by latest can be installed over runningexepath, see the func Preflight.
+func (u *Updater) Preflight(latest LatestRelease, runningexepath string) (PreflightReport, error) {
+	if runningexepath == "" {
+		return PreflightReport{}, ErrorRunningExePathIsEmpty
+	}
+
+	report := PreflightReport{
+		ExecutablePath: runningexepath,
+		Dir:            filepath.Dir(runningexepath),
+		RequiredBytes:  uint64(latest.Size),
+		EnoughSpace:    true,
+	}
+
+	if fi, err := os.Stat(runningexepath); err == nil {
+		report.RequiredBytes += uint64(fi.Size())
+	}
+
+	status, err := u.fops.DiskStatus(report.Dir)
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+	case err != nil:
+		report.Problems = append(report.Problems, fmt.Sprintf("cannot determine free space of %s: %v", report.Dir, err))
+	default:
+		report.FreeBytes = status.Free
+		report.ReadOnlyMount = status.ReadOnly
+		report.EnoughSpace = status.Free >= report.RequiredBytes
+	}
+
+	if report.ReadOnlyMount {
+		report.Problems = append(report.Problems, fmt.Sprintf("%s is on a read-only mount", report.Dir))
+	}
+	if !report.EnoughSpace {
+		report.Problems = append(report.Problems, fmt.Sprintf("not enough free space in %s: %d bytes required, %d bytes free", report.Dir, report.RequiredBytes, report.FreeBytes))
+	}
+
+	if err := u.fops.CheckWritable(report.Dir); err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("%s is not writable: %v", report.Dir, err))
+	} else {
+		report.Writable = true
+	}
+
+	return report, nil
+}
@@ -0,0 +1,100 @@
+package update
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dhcgn/gh-update/internal"
+)
+
+type PreflightFileOperationsMock struct {
+	FileOperationsMock
+	status    internal.DiskStatus
+	statusErr error
+	writeErr  error
+}
+
+// CheckWritable implements internal.FileOperations
+func (m *PreflightFileOperationsMock) CheckWritable(dir string) error {
+	return m.writeErr
+}
+
+// DiskStatus implements internal.FileOperations
+func (m *PreflightFileOperationsMock) DiskStatus(dir string) (internal.DiskStatus, error) {
+	return m.status, m.statusErr
+}
+
+func TestPreflight(t *testing.T) {
+	latest := LatestRelease{
+		Name:    "myapp-v0.0.3-windows-amd64.zip",
+		Url:     `https://myapp-v0.0.3-windows-amd64.zip`,
+		Version: "v1.2.3",
+		Size:    1000,
+	}
+
+	tests := []struct {
+		name            string
+		fops            *PreflightFileOperationsMock
+		runningexepath  string
+		wantErr         bool
+		wantOK          bool
+		wantWritable    bool
+		wantEnoughSpace bool
+	}{
+		{
+			name:           "empty path",
+			fops:           &PreflightFileOperationsMock{},
+			runningexepath: "",
+			wantErr:        true,
+		},
+		{
+			name:            "all checks pass",
+			fops:            &PreflightFileOperationsMock{status: internal.DiskStatus{Free: 1 << 20}},
+			runningexepath:  "myapp.exe",
+			wantOK:          true,
+			wantWritable:    true,
+			wantEnoughSpace: true,
+		},
+		{
+			name:           "disk full",
+			fops:           &PreflightFileOperationsMock{status: internal.DiskStatus{Free: 10}},
+			runningexepath: "myapp.exe",
+			wantWritable:   true,
+		},
+		{
+			name:            "read-only mount",
+			fops:            &PreflightFileOperationsMock{status: internal.DiskStatus{Free: 1 << 20, ReadOnly: true}, writeErr: errors.New("read-only file system")},
+			runningexepath:  "myapp.exe",
+			wantEnoughSpace: true,
+		},
+		{
+			name:            "free space unknown",
+			fops:            &PreflightFileOperationsMock{statusErr: errors.ErrUnsupported},
+			runningexepath:  "myapp.exe",
+			wantOK:          true,
+			wantWritable:    true,
+			wantEnoughSpace: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fops = tt.fops
+			got, err := Preflight(latest, tt.runningexepath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Preflight() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.OK() != tt.wantOK {
+				t.Errorf("Preflight() OK = %v, want %v, problems %v", got.OK(), tt.wantOK, got.Problems)
+			}
+			if got.Writable != tt.wantWritable {
+				t.Errorf("Preflight() Writable = %v, want %v", got.Writable, tt.wantWritable)
+			}
+			if got.EnoughSpace != tt.wantEnoughSpace {
+				t.Errorf("Preflight() EnoughSpace = %v, want %v", got.EnoughSpace, tt.wantEnoughSpace)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package update
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dhcgn/gh-update/internal"
+)
+
+// PromptConfig configures PromptAndUpdate, all fields are optional.
+type PromptConfig struct {
+	// In is read for the answer, the default is os.Stdin.
+	// If In is a file which is not a terminal the update is declined without asking.
+	In io.Reader
+	// Out is written with the question and the progress, the default is os.Stdout.
+	Out io.Writer
+	// ChangelogLines limits the shown changelog, the default is 10 lines and a negative value shows all lines.
+	ChangelogLines int
+}
+
+// PromptAndUpdate shows the current and the new version, a truncated changelog and the asset size,
+// asks for confirmation and runs SelfUpdateAndRestart with a progress display.
+// It returns false without an error if there is no update or the user declined.
+func (u *Updater) PromptAndUpdate(runningexepath string, cfg PromptConfig) (bool, error) {
+	if cfg.In == nil {
+		cfg.In = os.Stdin
+	}
+	if cfg.Out == nil {
+		cfg.Out = os.Stdout
+	}
+	if cfg.ChangelogLines == 0 {
+		cfg.ChangelogLines = 10
+	}
+
+	latest, err := u.GetLatestVersion()
+	if errors.Is(err, ErrorNoNewVersionFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Fprintf(cfg.Out, "A new version %s is available, you have %s", latest.Version, u.version)
+	if latest.Size > 0 {
+		fmt.Fprintf(cfg.Out, " (download %s)", formatBytes(latest.Size))
+	}
+	fmt.Fprintln(cfg.Out)
+	if latest.Urgency == UrgencyMandatory {
+		fmt.Fprintf(cfg.Out, "This update is mandatory, %s is no longer supported.\n", u.version)
+	}
+
+	changelog := latest.Notes
+	if notes, err := u.Changelog(latest.Version); err == nil && len(notes) > 0 {
+		changelog = ChangelogMarkdown(notes)
+	}
+	if changelog = truncateLines(changelog, cfg.ChangelogLines); changelog != "" {
+		fmt.Fprintf(cfg.Out, "\n%s\n\n", changelog)
+	}
+
+	if f, ok := cfg.In.(*os.File); ok && !isTerminal(f) {
+		fmt.Fprintln(cfg.Out, "Not running in a terminal, skipping the update.")
+		return false, nil
+	}
+
+	fmt.Fprint(cfg.Out, "Update now? [y/N] ")
+	answer, _ := bufio.NewReader(cfg.In).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+	default:
+		return false, nil
+	}
+
+	err = u.withProgress(func(done, total int64) {
+		if total > 0 {
+			fmt.Fprintf(cfg.Out, "\rDownloading %3d%% (%s / %s)", done*100/total, formatBytes(done), formatBytes(total))
+		} else {
+			fmt.Fprintf(cfg.Out, "\rDownloading %s", formatBytes(done))
+		}
+	}).SelfUpdateAndRestart(latest, runningexepath)
+	fmt.Fprintln(cfg.Out)
+	if err != nil {
+		return false, err
+	}
+	fmt.Fprintf(cfg.Out, "Updated to %s, restarting.\n", latest.Version)
+	return true, nil
+}
+
+// withProgress returns a copy of the Updater which reports the download progress to fn.
+func (u *Updater) withProgress(fn func(done, total int64)) *Updater {
+	c := *u
+	if wo, ok := c.webop.(internal.WebOperationsImpl); ok {
+		wo.Progress = fn
+		c.webop = wo
+	}
+	return &c
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// truncateLines returns the first n lines of s, all lines if n <= 0.
+func truncateLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if n <= 0 || len(lines) <= n {
+		return strings.TrimSpace(s)
+	}
+	return strings.Join(lines[:n], "\n") + "\n..."
+}
+
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
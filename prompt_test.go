@@ -0,0 +1,91 @@
+package update
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPromptAndUpdate(t *testing.T) {
+	notTerminal, err := os.Create(filepath.Join(t.TempDir(), "stdin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer notTerminal.Close()
+
+	tests := []struct {
+		name        string
+		in          io.Reader
+		want        bool
+		wantOutputs []string
+	}{
+		{
+			name:        "accepted",
+			in:          strings.NewReader("y\n"),
+			want:        true,
+			wantOutputs: []string{"A new version v1.2.3 is available, you have v0.0.2", "What's new", "Update now? [y/N]", "Updated to v1.2.3"},
+		},
+		{
+			name:        "declined",
+			in:          strings.NewReader("\n"),
+			wantOutputs: []string{"Update now? [y/N]"},
+		},
+		{
+			name:        "not a terminal",
+			in:          notTerminal,
+			wantOutputs: []string{"Not running in a terminal"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := New(
+				WithRepository("owner/repo"),
+				WithVersion("v0.0.2"),
+				WithAssetFilter("^myapp-.*windows.*zip$"),
+				WithSource(&WebOperationsMock{}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u.fops = &FileOperationsMock{}
+			u.osps = &OsOperationsMock{}
+
+			var out bytes.Buffer
+			got, err := u.PromptAndUpdate("myapp.exe", PromptConfig{In: tt.in, Out: &out})
+			if err != nil {
+				t.Fatalf("PromptAndUpdate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("PromptAndUpdate() = %v, want %v", got, tt.want)
+			}
+			for _, want := range tt.wantOutputs {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("PromptAndUpdate() output %q does not contain %q", out.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestTruncateLines(t *testing.T) {
+	if got := truncateLines("a\nb\nc\n", 2); got != "a\nb\n..." {
+		t.Errorf("truncateLines() = %q", got)
+	}
+	if got := truncateLines("a\nb\n", 2); got != "a\nb" {
+		t.Errorf("truncateLines() = %q", got)
+	}
+	if got := truncateLines("a\nb\nc\n", -1); got != "a\nb\nc" {
+		t.Errorf("truncateLines() without limit = %q", got)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for b, want := range map[int64]string{512: "512 B", 2048: "2.0 KiB", 80 * 1024 * 1024: "80.0 MiB"} {
+		if got := formatBytes(b); got != want {
+			t.Errorf("formatBytes(%d) = %v, want %v", b, got, want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package update
+
+import "time"
+
+// WithMinReleaseAge only offers releases which were published at least age ago,
+// so a quickly withdrawn release never reaches this installation.
+// Mandatory updates, see Manifest, are offered regardless of their age.
+func WithMinReleaseAge(age time.Duration) Option {
+	return func(u *Updater) {
+		u.minAge = age
+	}
+}
+
+// checkReleaseAge returns a *ReleaseAgeError if latest is younger than the minimum release age.
+func (u *Updater) checkReleaseAge(latest LatestRelease) error {
+	if u.minAge <= 0 {
+		return nil
+	}
+	eligibleAt := latest.PublishedAt.Add(u.minAge)
+	if u.now().Before(eligibleAt) {
+		u.log.Info("release too new", "version", latest.Version, "published_at", latest.PublishedAt, "eligible_at", eligibleAt)
+		return &ReleaseAgeError{Version: latest.Version, PublishedAt: latest.PublishedAt, EligibleAt: eligibleAt}
+	}
+	return nil
+}
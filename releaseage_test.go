@@ -0,0 +1,57 @@
+package update
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetLatestVersionMinReleaseAge(t *testing.T) {
+	// The release of WebOperationsMock is published at 2023-12-24 12:00 UTC
+	published := time.Date(2023, 12, 24, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		minAge   time.Duration
+		now      time.Time
+		manifest string
+		wantErr  bool
+	}{
+		{name: "no minimum age", now: published, manifest: `{}`},
+		{name: "old enough", minAge: 48 * time.Hour, now: published.Add(49 * time.Hour), manifest: `{}`},
+		{name: "too new", minAge: 48 * time.Hour, now: published.Add(time.Hour), wantErr: true, manifest: `{}`},
+		{name: "too new but recommended", minAge: 48 * time.Hour, now: published.Add(time.Hour), manifest: `{"recommended": true}`, wantErr: true},
+		{name: "too new but mandatory", minAge: 48 * time.Hour, now: published.Add(time.Hour), manifest: `{"minimum_version": "v1.0.0"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := New(
+				WithRepository("owner/repo"),
+				WithVersion("v0.0.2"),
+				WithAssetFilter("^myapp-.*windows.*zip$"),
+				WithSource(&ManifestWebOperationsMock{manifest: tt.manifest}),
+				WithMinReleaseAge(tt.minAge),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u.now = func() time.Time { return tt.now }
+
+			_, err = u.GetLatestVersion()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetLatestVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+
+			var ageErr *ReleaseAgeError
+			if !errors.As(err, &ageErr) || !ageErr.EligibleAt.Equal(published.Add(tt.minAge)) {
+				t.Errorf("GetLatestVersion() error = %#v, want *ReleaseAgeError eligible at %v", err, published.Add(tt.minAge))
+			}
+			if !errors.Is(err, ErrorNoNewVersionFound) {
+				t.Errorf("GetLatestVersion() error = %v, want it to match ErrorNoNewVersionFound", err)
+			}
+		})
+	}
+}
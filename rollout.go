@@ -0,0 +1,70 @@
+package update
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrorNotInRollout is returned if the latest release is in a staged rollout which does not include this installation.
+// It wraps ErrorNoNewVersionFound.
+var ErrorNotInRollout = fmt.Errorf("%w: installation is not in the staged rollout", ErrorNoNewVersionFound)
+
+// DefaultInstallID returns a random ID which is created on the first call and stored in the user config directory,
+// it is used to decide if this installation is part of a staged rollout.
+func DefaultInstallID() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "gh-update", "install-id")
+
+	if b, err := os.ReadFile(path); err == nil && len(strings.TrimSpace(string(b))) > 0 {
+		return strings.TrimSpace(string(b)), nil
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(id), 0600); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// rolloutBucket maps the installation deterministically to a bucket from 0 to 99 for a release.
+func rolloutBucket(installID, repo, version string) int {
+	sum := sha256.Sum256([]byte(installID + "/" + repo + "/" + version))
+	return int(binary.BigEndian.Uint64(sum[:8]) % 100)
+}
+
+// inRollout reports if this installation is offered the release with manifest m.
+func (u *Updater) inRollout(m Manifest, version string) (bool, error) {
+	if m.RolloutPercentage == nil || *m.RolloutPercentage >= 100 {
+		return true, nil
+	}
+
+	id := u.installID
+	if id == "" {
+		var err error
+		id, err = DefaultInstallID()
+		if err != nil {
+			return false, fmt.Errorf("install id for staged rollout: %w", err)
+		}
+	}
+
+	bucket := rolloutBucket(id, u.repo, version)
+	u.log.Info("staged rollout", "version", version, "percentage", *m.RolloutPercentage, "bucket", bucket)
+	return bucket < *m.RolloutPercentage, nil
+}
@@ -0,0 +1,59 @@
+package update
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRolloutBucket(t *testing.T) {
+	if rolloutBucket("id", "owner/repo", "v1.2.3") != rolloutBucket("id", "owner/repo", "v1.2.3") {
+		t.Errorf("rolloutBucket() is not deterministic")
+	}
+
+	in := 0
+	for i := 0; i < 10000; i++ {
+		if rolloutBucket(fmt.Sprint("install-", i), "owner/repo", "v1.2.3") < 20 {
+			in++
+		}
+	}
+	if in < 1800 || in > 2200 {
+		t.Errorf("rolloutBucket() put %d of 10000 installations in a 20%% rollout", in)
+	}
+}
+
+func TestGetLatestVersionRollout(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		version  string
+		wantErr  error
+	}{
+		{name: "no rollout", manifest: `{}`, version: "v1.0.0"},
+		{name: "full rollout", manifest: `{"rollout_percentage": 100}`, version: "v1.0.0"},
+		{name: "not in rollout", manifest: `{"rollout_percentage": 0}`, version: "v1.0.0", wantErr: ErrorNotInRollout},
+		{name: "mandatory ignores rollout", manifest: `{"rollout_percentage": 0, "minimum_version": "v1.1.0"}`, version: "v1.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := New(
+				WithRepository("owner/repo"),
+				WithVersion(tt.version),
+				WithAssetFilter("^myapp-.*windows.*zip$"),
+				WithSource(&ManifestWebOperationsMock{manifest: tt.manifest}),
+				WithInstallID("test-install"),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = u.GetLatestVersion()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetLatestVersion() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && !errors.Is(err, ErrorNoNewVersionFound) {
+				t.Errorf("GetLatestVersion() error = %v, want it to wrap ErrorNoNewVersionFound", err)
+			}
+		})
+	}
+}
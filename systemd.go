@@ -0,0 +1,48 @@
+package update
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/dhcgn/gh-update/internal"
+)
+
+// RunningUnderSystemd reports if the application is the main process of a systemd service.
+// Processes started by the service, e.g. from a shell, inherit INVOCATION_ID and NOTIFY_SOCKET,
+// so SYSTEMD_EXEC_PID must be the pid of this process. Only systemd before v248, which does not set
+// SYSTEMD_EXEC_PID, is detected by the notification socket.
+func RunningUnderSystemd() bool {
+	env, ok := os.LookupEnv(internal.EnvSystemdExecPid)
+	if !ok {
+		return os.Getenv(internal.EnvNotifySocket) != ""
+	}
+	pid, err := strconv.Atoi(env)
+	return err == nil && pid == os.Getpid()
+}
+
+// WithSystemdRestart lets systemd restart the application after an update if it runs as a systemd service.
+// Instead of starting the new executable, which would escape the lifecycle of the unit,
+// SelfUpdateAndRestart sends STOPPING=1 to the notification socket, if any, and exits the process with exitCode.
+// Configure the unit to restart on it, e.g. with Restart=always or RestartForceExitStatus=exitCode.
+// exitCode must be in 1..255, New returns an error otherwise, as systemd does not restart after a successful exit.
+// The new process is not started by the library, so IsFirstStartAfterUpdate is false and
+// RunMigrations takes the previous version from its state file.
+// Outside of systemd the application is restarted as usual.
+func WithSystemdRestart(exitCode int) Option {
+	return func(u *Updater) {
+		u.systemdRestart = true
+		u.systemdExitCode = exitCode
+	}
+}
+
+// exitForSystemd exits the process after an update to latest so that systemd restarts the service.
+func (u *Updater) exitForSystemd(latest LatestRelease) {
+	if os.Getenv(internal.EnvNotifySocket) != "" {
+		if err := u.osps.Notify(fmt.Sprintf("STOPPING=1\nSTATUS=Restarting into %s", latest.Version)); err != nil {
+			u.log.Warn("systemd notification failed", "error", err)
+		}
+	}
+	u.log.Info("exit for systemd restart", "version", latest.Version, "code", u.systemdExitCode)
+	u.osps.Exit(u.systemdExitCode)
+}
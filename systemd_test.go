@@ -0,0 +1,118 @@
+package update
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/dhcgn/gh-update/internal"
+)
+
+type SystemdOsOperationsMock struct {
+	OsOperationsMock
+	restarts int
+	notified []string
+	exitCode int
+}
+
+// Restart implements internal.OsOperations
+func (o *SystemdOsOperationsMock) Restart(opts internal.RestartOptions) (*os.Process, error) {
+	o.restarts++
+	return nil, nil
+}
+
+// Notify implements internal.OsOperations
+func (o *SystemdOsOperationsMock) Notify(state string) error {
+	o.notified = append(o.notified, state)
+	return nil
+}
+
+// Exit implements internal.OsOperations
+func (o *SystemdOsOperationsMock) Exit(code int) {
+	o.exitCode = code
+}
+
+func TestSystemdRestart(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+	tests := []struct {
+		name         string
+		execPid      string
+		notifySocket string
+		wantRestarts int
+		wantNotified int
+		wantExitCode int
+	}{
+		{name: "not under systemd", wantRestarts: 1},
+		{name: "service", execPid: pid, wantExitCode: 75},
+		{name: "notify service", execPid: pid, notifySocket: "/run/systemd/notify", wantNotified: 1, wantExitCode: 75},
+		{name: "started by a service", execPid: strconv.Itoa(os.Getppid()), wantRestarts: 1},
+		{name: "started by a notify service", execPid: strconv.Itoa(os.Getppid()), notifySocket: "/run/systemd/notify", wantRestarts: 1},
+		{name: "notify service of old systemd", notifySocket: "/run/systemd/notify", wantNotified: 1, wantExitCode: 75},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a shell in a service inherits INVOCATION_ID
+			t.Setenv("INVOCATION_ID", "0123")
+			t.Setenv(internal.EnvSystemdExecPid, tt.execPid)
+			if tt.execPid == "" {
+				os.Unsetenv(internal.EnvSystemdExecPid)
+			}
+			t.Setenv(internal.EnvNotifySocket, tt.notifySocket)
+
+			u, err := New(
+				WithRepository("owner/repo"),
+				WithVersion("v0.0.2"),
+				WithAssetFilter("^myapp-.*windows.*zip$"),
+				WithSource(&WebOperationsMock{}),
+				WithSystemdRestart(75),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			osps := &SystemdOsOperationsMock{}
+			u.fops = &FileOperationsMock{}
+			u.osps = osps
+
+			if err := u.SelfUpdateWithLatestAndRestart(`C:\myapp.exe`); err != nil {
+				t.Fatalf("SelfUpdateWithLatestAndRestart() error = %v", err)
+			}
+			if osps.restarts != tt.wantRestarts || len(osps.notified) != tt.wantNotified || osps.exitCode != tt.wantExitCode {
+				t.Errorf("restarts = %d, notified = %q, exit code = %d, want %d, %d, %d",
+					osps.restarts, osps.notified, osps.exitCode, tt.wantRestarts, tt.wantNotified, tt.wantExitCode)
+			}
+		})
+	}
+}
+
+func TestSystemdRestartExitCode(t *testing.T) {
+	for _, code := range []int{0, -1, 256} {
+		if _, err := New(WithRepository("owner/repo"), WithVersion("v0.0.2"), WithSystemdRestart(code)); err == nil {
+			t.Errorf("New() with systemd restart exit code %d error = nil", code)
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unixgram sockets on windows")
+	}
+	socket := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv(internal.EnvNotifySocket, socket)
+
+	if err := (internal.OsOperationsImpl{}).Notify("STOPPING=1"); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	b := make([]byte, 64)
+	n, err := conn.Read(b)
+	if err != nil || string(b[:n]) != "STOPPING=1" {
+		t.Errorf("notification = %q, %v", b[:n], err)
+	}
+}
@@ -0,0 +1,14 @@
+package types
+
+// BuildInfo is the Go build information embedded in an executable.
+type BuildInfo struct {
+	// Path is the import path of the main package.
+	Path string
+	// ModulePath is the path of the main module.
+	ModulePath string
+	GoVersion  string
+	// Revision is the version control revision, Time its commit time and Modified reports uncommitted changes.
+	Revision string
+	Time     string
+	Modified bool
+}
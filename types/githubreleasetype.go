@@ -8,7 +8,7 @@ type GithubReleaseResult struct {
 	// URL       string `json:"url"`
 	// AssetsURL string `json:"assets_url"`
 	// UploadURL string `json:"upload_url"`
-	// HTMLURL   string `json:"html_url"`
+	HTMLURL string `json:"html_url"`
 	// ID        int    `json:"id"`
 	// Author    struct {
 	// 	Login             string `json:"login"`
@@ -33,15 +33,15 @@ type GithubReleaseResult struct {
 	// NodeID          string    `json:"node_id"`
 	TagName string `json:"tag_name"`
 	// TargetCommitish string    `json:"target_commitish"`
-	// Name            string    `json:"name"`
-	// Draft           bool      `json:"draft"`
-	// Prerelease      bool      `json:"prerelease"`
+	Name       string `json:"name"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
 	// CreatedAt       time.Time `json:"created_at"`
 	PublishedAt time.Time `json:"published_at"`
 	Assets      []Assets  `json:"assets"`
 	// TarballURL    string `json:"tarball_url"`
 	// ZipballURL    string `json:"zipball_url"`
-	// Body          string `json:"body"`
+	Body string `json:"body"`
 	// MentionsCount int    `json:"mentions_count,omitempty"`
 }
 
@@ -73,7 +73,7 @@ type Assets struct {
 	// } `json:"uploader"`
 	// ContentType        string    `json:"content_type"`
 	// State              string    `json:"state"`
-	// Size               int       `json:"size"`
+	Size int64 `json:"size"`
 	// DownloadCount      int       `json:"download_count"`
 	// CreatedAt time.Time `json:"created_at"`
 	// UpdatedAt          time.Time `json:"updated_at"`
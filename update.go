@@ -2,10 +2,10 @@ package update
 
 import (
 	"fmt"
-	"net/url"
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/dhcgn/gh-update/internal"
 	"github.com/dhcgn/gh-update/types"
@@ -23,6 +23,9 @@ var (
 	ErrorRunningExePathIsEmpty = fmt.Errorf("runningexepath is empty")
 )
 
+// SetTestUpdateAssetPath uses the local file path as the asset of a fake release for the package level functions, for testing only.
+//
+// Deprecated: Use New with WithTestUpdateAssetPath.
 func SetTestUpdateAssetPath(path string) {
 	webop = internal.WebOperationsImpl{
 		TestUpdateAssetPath: path,
@@ -46,125 +49,151 @@ func GetOldPid() string {
 // It removes the backup of the old executable, executablePath is the path to the new currently running executable.
 // A retry is done if the backup file is still in use.
 func CleanUpAfterUpdate(executablePath string, oldpid string) error {
-	return fops.RemoveExecutable(executablePath, oldpid, 1)
+	return defaultUpdater("", "", AutoAssetFilter).CleanUpAfterUpdate(executablePath, oldpid)
 }
 
 type LatestRelease struct {
 	Name    string
 	Url     string
 	Version string
+	Size    int64
+	// Title is the name of the release, Notes the release body in markdown.
+	Title       string
+	Notes       string
+	PublishedAt time.Time
+	// HTMLURL is the url of the release page.
+	HTMLURL string
+	// Urgency tells if the update is mandatory, recommended or optional, see Manifest.
+	Urgency        Urgency
+	MinimumVersion string
+	// PatchName and PatchUrl are set if the release contains a delta patch
+	// from the current version, see package delta.
+	PatchName string
+	PatchUrl  string
 }
 
 // GetLatestVersion get the latest release from github information.
 // The latest (newest) release must have a different version than the current version.
 // name is the name of the github repository, e.g. "dhcgn/gh-update".
 // version is the current version of the application.
-// assetfilter is a regex to filter the assets of the release, e.g. "^myapp-.*windows.*zip$".
+// assetfilter is a regex to filter the assets of the release, e.g. "^myapp-.*windows.*zip$",
+// with AutoAssetFilter the asset for the running GOOS/GOARCH is selected, see RankAssets.
 // The returned LatestRelease contains the name of the asset and the url to download the asset
 // and can be used with the func SelfUpdateAndRestart.
 func GetLatestVersion(name string, version string, assetfilter string) (LatestRelease, error) {
-	// https://api.github.com/repos/dhcgn/workplace-sync/releases
-	u, err := url.JoinPath("https://api.github.com/repos/", name, "releases", "latest")
-	if err != nil {
-		return LatestRelease{}, err
-	}
-
-	assetRegex, err := regexp.Compile(assetfilter)
-	if err != nil {
-		return LatestRelease{}, err
-	}
-
-	latestRelease, err := webop.GetGithubRelease(u)
-	if err != nil {
-		return LatestRelease{}, err
-	}
-
-	if latestRelease.TagName == version {
-		return LatestRelease{}, ErrorNoNewVersionFound
-	}
-
-	assets := make([]types.Assets, 0)
-	for _, asset := range latestRelease.Assets {
-		if assetRegex.Match([]byte(asset.Name)) {
-			assets = append(assets, asset)
-		}
-	}
-
-	if len(assets) == 0 {
-		return LatestRelease{}, fmt.Errorf("no assets found with filter %s in version %v", assetfilter, latestRelease.TagName)
-	}
-	if len(assets) > 1 {
-		return LatestRelease{}, fmt.Errorf("multiple assets found with filter %s in version %v", assetfilter, latestRelease.TagName)
-	}
-
-	return LatestRelease{
-		Name:    assets[0].Name,
-		Url:     assets[0].BrowserDownloadURL,
-		Version: latestRelease.TagName,
-	}, nil
+	return defaultUpdater(name, version, assetfilter).GetLatestVersion()
 }
 
 // SelfUpdateAndRestart updates the current executable with the latest release from github and restarts the application.
 // LatestRelease is the result of GetLatestVersion.
 // runningexepath is the path to the currently running executable.
+// If the release contains a delta patch it is applied to the running executable,
+// the full asset is downloaded if the patch cannot be applied.
 func SelfUpdateAndRestart(latest LatestRelease, runningexepath string) error {
-	if latest.Version == "" || latest.Url == "" || latest.Name == "" {
-		return ErrorLatestNotValid
-	}
+	return defaultUpdater("", "", AutoAssetFilter).SelfUpdateAndRestart(latest, runningexepath)
+}
 
-	if runningexepath == "" {
-		return ErrorRunningExePathIsEmpty
-	}
+// SelfUpdateWithLatestAndRestart updates the current executable with the latest release from github and restarts the application.
+// The latest (newest) release must have a different version than the current version.
+// name is the name of the github repository, e.g. "dhcgn/gh-update".
+// version is the current version of the application.
+// assetfilter is a regex to filter the assets of the release, e.g. "^myapp-.*windows.*zip$".
+// runningexepath is the path to the currently running executable.
+func SelfUpdateWithLatestAndRestart(name string, version string, assetfilter string, runningexepath string) error {
+	return defaultUpdater(name, version, assetfilter).SelfUpdateWithLatestAndRestart(runningexepath)
+}
 
-	assetData, err := webop.GetAssetReader(latest.Url)
-	if err != nil {
-		return err
-	}
+// Changelog returns the notes of every release between the current version and target, the oldest first.
+// name is the name of the github repository, e.g. "dhcgn/gh-update".
+// version is the current version of the application, its notes are not included.
+// target is the version to update to, e.g. LatestRelease.Version, an empty target includes all newer releases.
+// Drafts and pre-releases are skipped, use ChangelogMarkdown to get a single markdown text.
+func Changelog(name string, version string, target string) ([]ReleaseNotes, error) {
+	return defaultUpdater(name, version, AutoAssetFilter).Changelog(target)
+}
 
-	if strings.HasSuffix(latest.Name, ".zip") {
-		assetData, err = fops.Unzip(assetData)
-		if err != nil {
-			return err
-		}
-	}
+// Rollback restores the backup of the executable created by an update, executablePath is the path to the updated executable.
+// The backup exists until CleanUpAfterUpdate is called.
+func Rollback(executablePath string) error {
+	return defaultUpdater("", "", AutoAssetFilter).Rollback(executablePath)
+}
 
-	newpath, err := fops.CreateNewTempPath(runningexepath)
-	if err != nil {
-		return err
-	}
+// DryRun resolves the latest release, selects the asset and returns an UpdatePlan with
+// the versions, the asset, the paths to be renamed and the restart command
+// without touching the executable or restarting.
+// If download is true the new executable is downloaded to a temporary file and validated like Apply does,
+// its path, SHA-256 and a failed check are set in the plan.
+// The parameters are the same as for SelfUpdateWithLatestAndRestart.
+func DryRun(name string, version string, assetfilter string, runningexepath string, download bool) (UpdatePlan, error) {
+	return defaultUpdater(name, version, assetfilter).DryRun(runningexepath, download)
+}
 
-	err = fops.SaveTo(assetData, newpath)
-	if err != nil {
-		return err
+// defaultUpdater returns the Updater used by the package level functions.
+func defaultUpdater(name string, version string, assetfilter string) *Updater {
+	return &Updater{
+		repo:         name,
+		version:      version,
+		assetFilter:  assetfilter,
+		manifestName: DefaultManifestName,
+		now:          time.Now,
+		log:          internal.DiscardLogger,
+		fops:         fops,
+		osps:         osps,
+		webop:        webop,
 	}
-	err = fops.MoveRunningExeToBackup(runningexepath)
-	if err != nil {
-		return err
-	}
-	err = fops.MoveNewExeToOriginalExe(newpath, runningexepath)
-	if err != nil {
-		return err
+}
+
+func filterAssets(assets []types.Assets, assetRegex *regexp.Regexp) []types.Assets {
+	result := make([]types.Assets, 0)
+	for _, asset := range assets {
+		if assetRegex.Match([]byte(asset.Name)) {
+			result = append(result, asset)
+		}
 	}
+	return result
+}
 
-	err = osps.Restart(runningexepath)
-	if err != nil {
-		return err
+// selectPlatformAssets returns the best ranked assets for platform,
+// more than one asset is returned if several share the best score.
+func selectPlatformAssets(assets []types.Assets, platform Platform) []types.Assets {
+	names := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		names = append(names, asset.Name)
 	}
 
-	return nil
+	result := make([]types.Assets, 0)
+	ranked := RankAssets(names, platform)
+	for _, c := range ranked {
+		if c.Score != ranked[0].Score {
+			break
+		}
+		for _, asset := range assets {
+			if asset.Name == c.Name {
+				result = append(result, asset)
+			}
+		}
+	}
+	return result
 }
 
-// SelfUpdateWithLatestAndRestart updates the current executable with the latest release from github and restarts the application.
-// The latest (newest) release must have a different version than the current version.
-// name is the name of the github repository, e.g. "dhcgn/gh-update".
-// version is the current version of the application.
-// assetfilter is a regex to filter the assets of the release, e.g. "^myapp-.*windows.*zip$".
-// runningexepath is the path to the currently running executable.
-func SelfUpdateWithLatestAndRestart(name string, version string, assetfilter string, runningexepath string) error {
-	latest, err := GetLatestVersion(name, version, assetfilter)
-	if err != nil {
-		return err
+// findPatchAsset returns the patch asset from version from to version to, e.g. "myapp_v1.2.0_to_v1.3.0.patch".
+// If there are patches for several applications, the one whose prefix matches the name of the full asset is used.
+func findPatchAsset(assets []types.Assets, from, to, assetName string) (types.Assets, bool) {
+	suffix := "_" + from + "_to_" + to + ".patch"
+	candidates := make([]types.Assets, 0)
+	for _, asset := range assets {
+		if strings.HasSuffix(asset.Name, suffix) {
+			candidates = append(candidates, asset)
+		}
 	}
 
-	return SelfUpdateAndRestart(latest, runningexepath)
+	if len(candidates) == 1 {
+		return candidates[0], true
+	}
+	for _, c := range candidates {
+		if strings.HasPrefix(assetName, strings.TrimSuffix(c.Name, suffix)) {
+			return c, true
+		}
+	}
+	return types.Assets{}, false
 }
@@ -1,10 +1,13 @@
 package update
 
 import (
+	"os"
 	"reflect"
+	"runtime"
 	"testing"
 	"time"
 
+	"github.com/dhcgn/gh-update/delta"
 	"github.com/dhcgn/gh-update/internal"
 	"github.com/dhcgn/gh-update/types"
 )
@@ -65,11 +68,41 @@ func TestSelfUpdateWithLatestAndRestart(t *testing.T) {
 
 type FileOperationsMock struct{}
 
+// Lock implements internal.FileOperations
+func (*FileOperationsMock) Lock(path string) (unlock func() error, err error) {
+	return func() error { return nil }, nil
+}
+
+// BuildInfo implements internal.FileOperations
+func (*FileOperationsMock) BuildInfo(path string) (types.BuildInfo, error) {
+	return types.BuildInfo{}, nil
+}
+
+// ExecutableInfo implements internal.FileOperations
+func (*FileOperationsMock) ExecutableInfo(path string) (internal.ExecutableInfo, error) {
+	return internal.ExecutableInfo{Format: internal.ExecutableFormat(runtime.GOOS), Archs: []string{runtime.GOARCH}}, nil
+}
+
 // RemoveExecutable implements internal.FileOperations
 func (*FileOperationsMock) RemoveExecutable(p string, pid string, try int) error {
 	return nil
 }
 
+// RestoreBackup implements internal.FileOperations
+func (*FileOperationsMock) RestoreBackup(p string) error {
+	return nil
+}
+
+// CheckWritable implements internal.FileOperations
+func (*FileOperationsMock) CheckWritable(dir string) error {
+	return nil
+}
+
+// DiskStatus implements internal.FileOperations
+func (*FileOperationsMock) DiskStatus(dir string) (internal.DiskStatus, error) {
+	return internal.DiskStatus{Free: 1 << 30}, nil
+}
+
 // CreateNewTempPath implements internal.FileOperations
 func (*FileOperationsMock) CreateNewTempPath(p string) (newPath string, err error) {
 	return internal.FileOperationsImpl{}.CreateNewTempPath(p)
@@ -90,6 +123,41 @@ func (*FileOperationsMock) SaveTo(data []byte, path string) error {
 	return nil
 }
 
+// UnzipAll implements internal.FileOperations
+func (*FileOperationsMock) UnzipAll(zip []byte) ([]internal.ArchiveFile, error) {
+	return nil, nil
+}
+
+// Exists implements internal.FileOperations
+func (*FileOperationsMock) Exists(p string) bool {
+	return true
+}
+
+// RemoveAll implements internal.FileOperations
+func (*FileOperationsMock) RemoveAll(p string) error {
+	return nil
+}
+
+// ReplaceSymlink implements internal.FileOperations
+func (*FileOperationsMock) ReplaceSymlink(target string, link string) error {
+	return nil
+}
+
+// Readlink implements internal.FileOperations
+func (*FileOperationsMock) Readlink(link string) (string, error) {
+	return "", nil
+}
+
+// ReadDir implements internal.FileOperations
+func (*FileOperationsMock) ReadDir(dir string) ([]string, error) {
+	return nil, nil
+}
+
+// ReadFile implements internal.FileOperations
+func (*FileOperationsMock) ReadFile(path string) ([]byte, error) {
+	return nil, nil
+}
+
 // Unzip implements internal.FileOperations
 func (*FileOperationsMock) Unzip(zip []byte) (data []byte, err error) {
 	return nil, nil
@@ -98,10 +166,28 @@ func (*FileOperationsMock) Unzip(zip []byte) (data []byte, err error) {
 type OsOperationsMock struct{}
 
 // Restart implements internal.OsOperations
-func (*OsOperationsMock) Restart(path string) error {
+func (*OsOperationsMock) Restart(opts internal.RestartOptions) (*os.Process, error) {
+	return nil, nil
+}
+
+// Command implements internal.OsOperations
+func (*OsOperationsMock) Command(opts internal.RestartOptions) []string {
+	return []string{opts.Path}
+}
+
+// Run implements internal.OsOperations
+func (*OsOperationsMock) Run(path string, args []string, timeout time.Duration) ([]byte, error) {
+	return nil, nil
+}
+
+// Notify implements internal.OsOperations
+func (*OsOperationsMock) Notify(state string) error {
 	return nil
 }
 
+// Exit implements internal.OsOperations
+func (*OsOperationsMock) Exit(code int) {}
+
 type WebOperationsMock struct{}
 
 // GetAssetReader implements internal.WebOperations
@@ -109,11 +195,19 @@ func (*WebOperationsMock) GetAssetReader(url string) (data []byte, err error) {
 	return nil, nil
 }
 
+// GetGithubReleases implements internal.WebOperations
+func (*WebOperationsMock) GetGithubReleases(url string) ([]types.GithubReleaseResult, string, error) {
+	return nil, "", nil
+}
+
 // GetGithubRelease implements internal.WebOperations
 func (*WebOperationsMock) GetGithubRelease(url string) (*types.GithubReleaseResult, error) {
 	r := &types.GithubReleaseResult{
 		TagName:     "v1.2.3",
-		PublishedAt: time.Time{},
+		Name:        "Release v1.2.3",
+		Body:        "## What's new\n\n- Feature",
+		HTMLURL:     "https://github.com/owner/repo/releases/tag/v1.2.3",
+		PublishedAt: time.Date(2023, 12, 24, 12, 0, 0, 0, time.UTC),
 		Assets: []types.Assets{
 			{
 				Name:               "myapp-v0.0.3-windows-amd64.zip",
@@ -149,9 +243,14 @@ func TestGetLatestVersion(t *testing.T) {
 				version:     "v0.0.2",
 			},
 			want: LatestRelease{
-				Name:    "myapp-v0.0.3-windows-amd64.zip",
-				Url:     `https://myapp-v0.0.3-windows-amd64.zip`,
-				Version: "v1.2.3",
+				Name:        "myapp-v0.0.3-windows-amd64.zip",
+				Url:         `https://myapp-v0.0.3-windows-amd64.zip`,
+				Version:     "v1.2.3",
+				Title:       "Release v1.2.3",
+				Notes:       "## What's new\n\n- Feature",
+				PublishedAt: time.Date(2023, 12, 24, 12, 0, 0, 0, time.UTC),
+				HTMLURL:     "https://github.com/owner/repo/releases/tag/v1.2.3",
+				Urgency:     UrgencyOptional,
 			},
 		},
 	}
@@ -233,3 +332,115 @@ func TestSelfUpdateAndRestart(t *testing.T) {
 		})
 	}
 }
+
+func TestFindPatchAsset(t *testing.T) {
+	assets := []types.Assets{
+		{Name: "myapp-v1.3.0-windows-amd64.zip"},
+		{Name: "myapp_v1.2.0_to_v1.3.0.patch"},
+		{Name: "myapp_v1.1.0_to_v1.3.0.patch"},
+		{Name: "othertool_v1.2.0_to_v1.3.0.patch"},
+	}
+
+	tests := []struct {
+		name      string
+		assets    []types.Assets
+		from      string
+		assetName string
+		want      string
+		wantOk    bool
+	}{
+		{
+			name:      "single patch",
+			assets:    assets[:3],
+			from:      "v1.2.0",
+			assetName: "myapp-v1.3.0-windows-amd64.zip",
+			want:      "myapp_v1.2.0_to_v1.3.0.patch",
+			wantOk:    true,
+		},
+		{
+			name:      "patch of several applications",
+			assets:    assets,
+			from:      "v1.2.0",
+			assetName: "myapp-v1.3.0-windows-amd64.zip",
+			want:      "myapp_v1.2.0_to_v1.3.0.patch",
+			wantOk:    true,
+		},
+		{
+			name:      "no patch for version",
+			assets:    assets,
+			from:      "v1.0.0",
+			assetName: "myapp-v1.3.0-windows-amd64.zip",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := findPatchAsset(tt.assets, tt.from, "v1.3.0", tt.assetName)
+			if ok != tt.wantOk || got.Name != tt.want {
+				t.Errorf("findPatchAsset() = %v, %v, want %v, %v", got.Name, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+type AssetWebOperationsMock struct {
+	WebOperationsMock
+	assets map[string][]byte
+}
+
+// GetAssetReader implements internal.WebOperations
+func (m *AssetWebOperationsMock) GetAssetReader(url string) (data []byte, err error) {
+	return m.assets[url], nil
+}
+
+func TestDownloadUpdate(t *testing.T) {
+
+	newExe := []byte("new executable")
+	patch, err := delta.Diff(nil, newExe)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		latest      LatestRelease
+		assets      map[string][]byte
+		want        []byte
+		wantPatched bool
+	}{
+		{
+			name:        "patch",
+			latest:      LatestRelease{Name: "myapp.exe", Url: "https://full", PatchUrl: "https://patch"},
+			assets:      map[string][]byte{"https://full": []byte("full"), "https://patch": patch},
+			want:        newExe,
+			wantPatched: true,
+		},
+		{
+			name:   "broken patch falls back to full asset",
+			latest: LatestRelease{Name: "myapp.exe", Url: "https://full", PatchUrl: "https://patch"},
+			assets: map[string][]byte{"https://full": []byte("full"), "https://patch": []byte("broken")},
+			want:   []byte("full"),
+		},
+		{
+			name:   "no patch",
+			latest: LatestRelease{Name: "myapp.exe", Url: "https://full"},
+			assets: map[string][]byte{"https://full": []byte("full")},
+			want:   []byte("full"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &Updater{log: internal.DiscardLogger, fops: &FileOperationsMock{}, webop: &AssetWebOperationsMock{assets: tt.assets}}
+			files, patched, err := u.downloadUpdate(tt.latest, "myapp.exe")
+			if err != nil {
+				t.Fatalf("downloadUpdate() error = %v", err)
+			}
+			got := files[0].data
+			if patched != tt.wantPatched {
+				t.Errorf("downloadUpdate() patched = %v, want %v", patched, tt.wantPatched)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("downloadUpdate() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
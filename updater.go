@@ -0,0 +1,644 @@
+package update
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/dhcgn/gh-update/delta"
+	"github.com/dhcgn/gh-update/internal"
+	"github.com/dhcgn/gh-update/types"
+)
+
+// Source provides the releases and assets of a repository,
+// the default source is the GitHub API.
+type Source interface {
+	// GetGithubRelease returns the release of the GitHub API url, e.g. https://api.github.com/repos/owner/repo/releases/latest.
+	GetGithubRelease(url string) (*types.GithubReleaseResult, error)
+	// GetGithubReleases returns one page of the releases list url, next is the url of the next page or empty.
+	GetGithubReleases(url string) (releases []types.GithubReleaseResult, next string, err error)
+	// GetAssetReader returns the content of the asset url.
+	GetAssetReader(url string) (data []byte, err error)
+}
+
+// Hooks are called by the Updater during an update, all hooks are optional.
+type Hooks struct {
+	// PreUpdate is called after the new executable is downloaded and before the running executable is replaced,
+	// e.g. to flush queues or close connections. A non-nil error cancels the update, return Veto or Postpone
+	// to give the caller a reason, see VetoError.
+	PreUpdate func(latest LatestRelease) error
+	// BeforeUpdate is called right after PreUpdate.
+	//
+	// Deprecated: Use PreUpdate, which is called at the same point and can cancel the update.
+	BeforeUpdate func(latest LatestRelease)
+	// AfterUpdate is called after the running executable is replaced and before the restart.
+	AfterUpdate func(latest LatestRelease)
+}
+
+// Updater checks for and installs updates of one application from its GitHub releases.
+// Create it with New, an Updater can be used from several goroutines.
+type Updater struct {
+	repo                string
+	version             string
+	assetFilter         string
+	manifestName        string
+	installID           string
+	minAge              time.Duration
+	companions          map[string]string
+	exeMember           string
+	layoutRoot          string
+	migrations          []migration
+	migrationState      string
+	smokeTest           bool
+	skipExecutableCheck bool
+	skipBuildInfoCheck  bool
+	lockFile            string
+	lockWait            time.Duration
+	restartArgs         []string
+	restartFiles        []*os.File
+	graceful            bool
+	readyTimeout        time.Duration
+	listeners           []net.Listener
+	systemdRestart      bool
+	systemdExitCode     int
+	smokeTimeout        time.Duration
+	smokeArgs           []string
+	now                 func() time.Time
+	hooks               Hooks
+	log                 *slog.Logger
+
+	httpClient    *http.Client
+	testAssetPath string
+	progress      func(done, total int64)
+	source        Source
+
+	fops  internal.FileOperations
+	osps  internal.OsOperations
+	webop internal.WebOperations
+}
+
+// Option configures an Updater.
+type Option func(*Updater)
+
+// WithRepository sets the name of the github repository, e.g. "dhcgn/gh-update".
+func WithRepository(name string) Option {
+	return func(u *Updater) {
+		u.repo = name
+	}
+}
+
+// WithVersion sets the current version of the application.
+func WithVersion(version string) Option {
+	return func(u *Updater) {
+		u.version = version
+	}
+}
+
+// WithAssetFilter sets the regex to filter the assets of the release, e.g. "^myapp-.*windows.*zip$".
+// The default is AutoAssetFilter.
+func WithAssetFilter(assetfilter string) Option {
+	return func(u *Updater) {
+		u.assetFilter = assetfilter
+	}
+}
+
+// WithManifestName sets the name of the manifest asset, the default is DefaultManifestName.
+func WithManifestName(name string) Option {
+	return func(u *Updater) {
+		u.manifestName = name
+	}
+}
+
+// WithInstallID sets the stable ID of this installation for staged rollouts, the default is DefaultInstallID.
+func WithInstallID(id string) Option {
+	return func(u *Updater) {
+		u.installID = id
+	}
+}
+
+// WithHTTPClient sets the client used for the GitHub API and the download of assets.
+func WithHTTPClient(client *http.Client) Option {
+	return func(u *Updater) {
+		u.httpClient = client
+	}
+}
+
+// WithSource replaces the GitHub API as source of releases and assets.
+func WithSource(source Source) Option {
+	return func(u *Updater) {
+		u.source = source
+	}
+}
+
+// WithTestUpdateAssetPath uses the local file path as the asset of a fake release, for testing only.
+func WithTestUpdateAssetPath(path string) Option {
+	return func(u *Updater) {
+		u.testAssetPath = path
+	}
+}
+
+// WithRestartArgs sets the arguments of the restarted application, the default are the arguments of this process.
+func WithRestartArgs(args ...string) Option {
+	return func(u *Updater) {
+		if args == nil {
+			args = []string{}
+		}
+		u.restartArgs = args
+	}
+}
+
+// WithRestartFiles passes open files to the restarted application as file descriptors 3, 4, ...
+func WithRestartFiles(files ...*os.File) Option {
+	return func(u *Updater) {
+		u.restartFiles = files
+	}
+}
+
+// WithHooks sets the hooks called during an update.
+func WithHooks(hooks Hooks) Option {
+	return func(u *Updater) {
+		u.hooks = hooks
+	}
+}
+
+// WithProgress sets a function which is called while an asset is downloaded, total is -1 if the size is unknown.
+func WithProgress(fn func(done, total int64)) Option {
+	return func(u *Updater) {
+		u.progress = fn
+	}
+}
+
+// WithLogger sets the logger for structured records of every update step, nothing is logged by default or for nil.
+func WithLogger(logger *slog.Logger) Option {
+	return func(u *Updater) {
+		if logger == nil {
+			logger = internal.DiscardLogger
+		}
+		u.log = logger
+	}
+}
+
+// New creates an Updater, WithRepository and WithVersion are required.
+func New(opts ...Option) (*Updater, error) {
+	u := &Updater{
+		assetFilter:  AutoAssetFilter,
+		manifestName: DefaultManifestName,
+		now:          time.Now,
+		log:          internal.DiscardLogger,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+
+	u.fops = internal.FileOperationsImpl{Logger: u.log}
+	u.osps = internal.OsOperationsImpl{Logger: u.log}
+	if u.source != nil {
+		u.webop = u.source
+	} else {
+		u.webop = internal.WebOperationsImpl{
+			TestUpdateAssetPath: u.testAssetPath,
+			Client:              u.httpClient,
+			Logger:              u.log,
+			Progress:            u.progress,
+		}
+	}
+
+	if u.repo == "" {
+		return nil, fmt.Errorf("repository is empty")
+	}
+	if u.version == "" {
+		return nil, fmt.Errorf("version is empty")
+	}
+	if u.systemdRestart && (u.systemdExitCode < 1 || u.systemdExitCode > 255) {
+		return nil, fmt.Errorf("systemd restart exit code %d is not in 1..255", u.systemdExitCode)
+	}
+	u.log = u.log.With("repository", u.repo)
+	return u, nil
+}
+
+// CleanUpAfterUpdate cleans up after an update, see the func CleanUpAfterUpdate.
+func (u *Updater) CleanUpAfterUpdate(executablePath string, oldpid string) error {
+	if u.layoutRoot != "" {
+		return u.cleanUpVersioned()
+	}
+	if err := u.fops.RemoveExecutable(executablePath, oldpid, 1); err != nil {
+		return &SwapError{Stage: StageCleanUp, From: internal.BackupPath(executablePath), Err: err}
+	}
+	for _, target := range u.companionTargets(executablePath) {
+		if err := u.fops.RemoveAll(internal.BackupPath(target)); err != nil {
+			return &SwapError{Stage: StageCleanUp, From: internal.BackupPath(target), Err: err}
+		}
+	}
+	return nil
+}
+
+// GetLatestVersion get the latest release from github information, see the func GetLatestVersion.
+func (u *Updater) GetLatestVersion() (LatestRelease, error) {
+	// https://api.github.com/repos/dhcgn/workplace-sync/releases
+	apiUrl, err := url.JoinPath("https://api.github.com/repos/", u.repo, "releases", "latest")
+	if err != nil {
+		return LatestRelease{}, err
+	}
+
+	assetfilter := u.assetFilter
+	var assetRegex *regexp.Regexp
+	if assetfilter != AutoAssetFilter {
+		assetRegex, err = regexp.Compile(assetfilter)
+		if err != nil {
+			return LatestRelease{}, fmt.Errorf("invalid asset filter: %w", err)
+		}
+	}
+
+	latestRelease, err := u.webop.GetGithubRelease(apiUrl)
+	if err != nil {
+		return LatestRelease{}, newDownloadError(apiUrl, err)
+	}
+
+	u.log.Info("latest release", "version", latestRelease.TagName, "current", u.version, "assets", len(latestRelease.Assets))
+	if latestRelease.TagName == u.version {
+		return LatestRelease{}, ErrorNoNewVersionFound
+	}
+
+	var assets []types.Assets
+	if assetRegex != nil {
+		assets = filterAssets(latestRelease.Assets, assetRegex)
+	} else {
+		assetfilter = "for " + runtime.GOOS + "/" + runtime.GOARCH
+		assets = selectPlatformAssets(latestRelease.Assets, CurrentPlatform())
+	}
+
+	u.log.Debug("matching assets", "filter", assetfilter, "count", len(assets))
+	if len(assets) != 1 {
+		e := &AssetSelectionError{Filter: assetfilter, Version: latestRelease.TagName}
+		for _, asset := range assets {
+			e.Candidates = append(e.Candidates, asset.Name)
+		}
+		return LatestRelease{}, e
+	}
+
+	latest := LatestRelease{
+		Name:        assets[0].Name,
+		Url:         assets[0].BrowserDownloadURL,
+		Version:     latestRelease.TagName,
+		Size:        assets[0].Size,
+		Title:       latestRelease.Name,
+		Notes:       latestRelease.Body,
+		PublishedAt: latestRelease.PublishedAt,
+		HTMLURL:     latestRelease.HTMLURL,
+	}
+	if patch, ok := findPatchAsset(latestRelease.Assets, u.version, latestRelease.TagName, assets[0].Name); ok {
+		latest.PatchName = patch.Name
+		latest.PatchUrl = patch.BrowserDownloadURL
+	}
+
+	manifest, err := u.manifest(latestRelease)
+	if err != nil {
+		return LatestRelease{}, err
+	}
+	latest.MinimumVersion = manifest.MinimumVersion
+	latest.Urgency = manifest.Urgency(u.version)
+
+	if latest.Urgency != UrgencyMandatory {
+		if err := u.checkReleaseAge(latest); err != nil {
+			return LatestRelease{}, err
+		}
+		ok, err := u.inRollout(manifest, latest.Version)
+		if err != nil {
+			return LatestRelease{}, err
+		}
+		if !ok {
+			return LatestRelease{}, ErrorNotInRollout
+		}
+	}
+
+	u.log.Info("asset chosen", "asset", latest.Name, "url", latest.Url, "size", latest.Size, "patch", latest.PatchName, "urgency", latest.Urgency)
+	return latest, nil
+}
+
+// SelfUpdateAndRestart updates the current executable and restarts the application, see the func SelfUpdateAndRestart.
+func (u *Updater) SelfUpdateAndRestart(latest LatestRelease, runningexepath string) error {
+	unlock, err := u.lock(runningexepath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return u.selfUpdateAndRestart(latest, runningexepath)
+}
+
+func (u *Updater) selfUpdateAndRestart(latest LatestRelease, runningexepath string) error {
+	if err := u.checkGracefulRestart(); err != nil {
+		return err
+	}
+	if _, err := u.apply(latest, runningexepath); err != nil {
+		return err
+	}
+
+	if u.systemdRestart && RunningUnderSystemd() {
+		u.exitForSystemd(latest)
+		return nil
+	}
+
+	opts := u.restartOptions(runningexepath)
+	var err error
+	if u.graceful {
+		err = u.gracefulRestart(opts)
+	} else {
+		_, err = u.osps.Restart(opts)
+	}
+	if err != nil {
+		return &RestartError{Path: opts.Path, Err: err}
+	}
+
+	return nil
+}
+
+// startDir is the working directory the application was started in, the restart uses it
+// even if the application changed its working directory since.
+var startDir, _ = os.Getwd()
+
+// restartOptions describes the restart into the updated executable runningexepath.
+func (u *Updater) restartOptions(runningexepath string) internal.RestartOptions {
+	opts := internal.RestartOptions{
+		Path:       u.restartPath(runningexepath),
+		Args:       u.restartArgs,
+		ExtraFiles: u.restartFiles,
+	}
+	if opts.Args == nil && len(os.Args) > 1 {
+		opts.Args = os.Args[1:]
+	}
+	opts.Dir = startDir
+	if u.version != "" {
+		opts.Env = append(opts.Env, internal.EnvPreviousVersion+"="+u.version)
+	}
+	return opts
+}
+
+// UpdateResult describes an installed update.
+type UpdateResult struct {
+	Release        LatestRelease
+	ExecutablePath string
+	PatchApplied   bool
+	// BuildInfo of the new executable, nil if it has no Go build information.
+	BuildInfo *types.BuildInfo
+}
+
+// Apply replaces the executable at exepath with latest without a restart,
+// the replaced executable is kept as backup for Rollback until CleanUpAfterUpdate.
+// Use it to update another binary than the running one.
+func (u *Updater) Apply(latest LatestRelease, exepath string) error {
+	_, err := u.ApplyWithResult(latest, exepath)
+	return err
+}
+
+// ApplyWithResult is Apply and returns a description of the installed update.
+func (u *Updater) ApplyWithResult(latest LatestRelease, exepath string) (*UpdateResult, error) {
+	unlock, err := u.lock(exepath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return u.apply(latest, exepath)
+}
+
+func (u *Updater) apply(latest LatestRelease, exepath string) (*UpdateResult, error) {
+	if latest.Version == "" || latest.Url == "" || latest.Name == "" {
+		return nil, ErrorLatestNotValid
+	}
+
+	if exepath == "" {
+		return nil, ErrorRunningExePathIsEmpty
+	}
+
+	u.log.Info("update", "from", u.version, "to", latest.Version, "path", exepath)
+	if u.layoutRoot != "" {
+		return u.applyVersioned(latest, exepath)
+	}
+
+	files, patched, err := u.downloadUpdate(latest, exepath)
+	if err != nil {
+		return nil, err
+	}
+	// another process may have installed the release while this one waited for the lock
+	if u.installed(files) {
+		return nil, fmt.Errorf("%w: %s is already installed", ErrorNoNewVersionFound, latest.Version)
+	}
+
+	targets, err := u.stage(files)
+	if err != nil {
+		return nil, err
+	}
+
+	staged, err := u.fops.CreateNewTempPath(exepath)
+	if err != nil {
+		return nil, &SwapError{Stage: StageSave, From: exepath, Err: err}
+	}
+	bi, err := u.validate(latest, exepath, staged)
+	if err != nil {
+		u.removeStaged(targets)
+		return nil, err
+	}
+
+	if err := u.preUpdate(latest); err != nil {
+		u.removeStaged(targets)
+		return nil, err
+	}
+
+	if u.hooks.BeforeUpdate != nil {
+		u.hooks.BeforeUpdate(latest)
+	}
+
+	if err := u.swap(targets); err != nil {
+		return nil, err
+	}
+
+	if u.hooks.AfterUpdate != nil {
+		u.hooks.AfterUpdate(latest)
+	}
+
+	return &UpdateResult{Release: latest, ExecutablePath: exepath, PatchApplied: patched, BuildInfo: bi}, nil
+}
+
+// installed reports if the targets of files already have the content of files.
+func (u *Updater) installed(files []updateFile) bool {
+	if len(files[0].data) == 0 {
+		return false
+	}
+	dirFiles := make(map[string]int)
+	for _, f := range files {
+		path := f.target
+		if f.rel != "" {
+			path = filepath.Join(f.target, f.rel)
+			dirFiles[f.target]++
+		}
+		current, err := u.fops.ReadFile(path)
+		if err != nil || !bytes.Equal(current, f.data) {
+			return false
+		}
+	}
+	// a companion directory must not contain files which the release removed
+	for dir, n := range dirFiles {
+		if countFiles(dir) != n {
+			return false
+		}
+	}
+	return true
+}
+
+// countFiles returns the number of files below dir, -1 if it cannot be read.
+func countFiles(dir string) int {
+	n := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return err
+	})
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// removeStaged removes the staged files of an update which is not swapped in.
+func (u *Updater) removeStaged(targets []string) {
+	for _, target := range targets {
+		if staged, err := u.fops.CreateNewTempPath(target); err == nil {
+			_ = u.fops.RemoveAll(staged)
+		}
+	}
+}
+
+// preUpdate calls the PreUpdate hook and returns its error as *VetoError.
+func (u *Updater) preUpdate(latest LatestRelease) error {
+	if u.hooks.PreUpdate == nil {
+		return nil
+	}
+	err := u.hooks.PreUpdate(latest)
+	if err == nil {
+		return nil
+	}
+	var veto *VetoError
+	if !errors.As(err, &veto) {
+		veto = &VetoError{Reason: err.Error(), Err: err}
+	}
+	u.log.Warn("update vetoed", "version", latest.Version, "reason", veto.Reason, "retry_after", veto.RetryAfter)
+	return veto
+}
+
+// Rollback restores the backup of the executable, see the func Rollback.
+func (u *Updater) Rollback(executablePath string) error {
+	unlock, err := u.lock(executablePath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if u.layoutRoot != "" {
+		return u.rollbackVersioned()
+	}
+	if executablePath == "" {
+		return ErrorRunningExePathIsEmpty
+	}
+	if err := u.fops.RestoreBackup(executablePath); err != nil {
+		return &SwapError{Stage: StageRollback, From: internal.BackupPath(executablePath), To: executablePath, Err: err}
+	}
+	for _, target := range u.companionTargets(executablePath) {
+		backup := internal.BackupPath(target)
+		if !u.fops.Exists(backup) {
+			continue
+		}
+		if err := u.fops.RemoveAll(target); err != nil {
+			return &SwapError{Stage: StageRollback, From: backup, To: target, Err: err}
+		}
+		if err := u.fops.MoveNewExeToOriginalExe(backup, target); err != nil {
+			return &SwapError{Stage: StageRollback, From: backup, To: target, Err: err}
+		}
+	}
+	return nil
+}
+
+// SelfUpdateWithLatestAndRestart updates the current executable with the latest release and restarts the application,
+// see the func SelfUpdateWithLatestAndRestart.
+func (u *Updater) SelfUpdateWithLatestAndRestart(runningexepath string) error {
+	unlock, err := u.lock(runningexepath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	latest, err := u.GetLatestVersion()
+	if err != nil {
+		return err
+	}
+
+	return u.selfUpdateAndRestart(latest, runningexepath)
+}
+
+// downloadUpdate returns the files of the update, the first is the new executable.
+// It tries the delta patch first and falls back to the full asset if there is no patch or it cannot be applied.
+// patched reports if the patch was used.
+func (u *Updater) downloadUpdate(latest LatestRelease, runningexepath string) (files []updateFile, patched bool, err error) {
+	if latest.PatchUrl != "" && len(u.companions) == 0 {
+		data, err := u.downloadAndApplyPatch(latest, runningexepath)
+		if err == nil {
+			u.log.Info("applied patch", "patch", latest.PatchName, "bytes", len(data))
+			return []updateFile{{target: runningexepath, data: data}}, true, nil
+		}
+		u.log.Warn("patch failed, downloading full asset", "patch", latest.PatchName, "error", err)
+	}
+
+	assetData, err := u.webop.GetAssetReader(latest.Url)
+	if err != nil {
+		return nil, false, newDownloadError(latest.Url, err)
+	}
+
+	if len(u.companions) > 0 {
+		if !strings.HasSuffix(latest.Name, ".zip") {
+			return nil, false, fmt.Errorf("companion files need a zip asset, got %s", latest.Name)
+		}
+		archive, err := u.fops.UnzipAll(assetData)
+		if err != nil {
+			return nil, false, fmt.Errorf("unzip %s: %w", latest.Name, err)
+		}
+		files, err := u.companionFiles(archive, runningexepath)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", latest.Name, err)
+		}
+		u.log.Debug("unzipped asset", "asset", latest.Name, "files", len(files))
+		return files, false, nil
+	}
+
+	if strings.HasSuffix(latest.Name, ".zip") {
+		assetData, err = u.fops.Unzip(assetData)
+		if err != nil {
+			return nil, false, fmt.Errorf("unzip %s: %w", latest.Name, err)
+		}
+		u.log.Debug("unzipped asset", "asset", latest.Name, "bytes", len(assetData))
+	}
+	return []updateFile{{target: runningexepath, data: assetData}}, false, nil
+}
+
+func (u *Updater) downloadAndApplyPatch(latest LatestRelease, runningexepath string) ([]byte, error) {
+	patch, err := u.webop.GetAssetReader(latest.PatchUrl)
+	if err != nil {
+		return nil, newDownloadError(latest.PatchUrl, err)
+	}
+	old, err := u.fops.ReadFile(runningexepath)
+	if err != nil {
+		return nil, err
+	}
+	return delta.Apply(old, patch)
+}
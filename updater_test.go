@@ -0,0 +1,237 @@
+package update
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhcgn/gh-update/internal"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{
+			name:    "no options",
+			wantErr: true,
+		},
+		{
+			name:    "no version",
+			opts:    []Option{WithRepository("owner/repo")},
+			wantErr: true,
+		},
+		{
+			name: "repository and version",
+			opts: []Option{WithRepository("owner/repo"), WithVersion("v0.0.1")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	client := &http.Client{Timeout: time.Second}
+	u, err := New(
+		WithRepository("owner/repo"),
+		WithVersion("v0.0.1"),
+		WithAssetFilter("^myapp-.*windows.*zip$"),
+		WithHTTPClient(client),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.assetFilter != "^myapp-.*windows.*zip$" {
+		t.Errorf("New() assetFilter = %v", u.assetFilter)
+	}
+	if wo, ok := u.webop.(internal.WebOperationsImpl); !ok || wo.Client != client {
+		t.Errorf("New() webop = %#v, want client to be set", u.webop)
+	}
+}
+
+func TestUpdaterSelfUpdateWithLatestAndRestart(t *testing.T) {
+	var before, after []string
+	u, err := New(
+		WithRepository("owner/repo"),
+		WithVersion("v0.0.2"),
+		WithAssetFilter("^myapp-.*windows.*zip$"),
+		WithSource(&WebOperationsMock{}),
+		WithHooks(Hooks{
+			BeforeUpdate: func(latest LatestRelease) { before = append(before, latest.Version) },
+			AfterUpdate:  func(latest LatestRelease) { after = append(after, latest.Version) },
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.fops = &FileOperationsMock{}
+	u.osps = &OsOperationsMock{}
+
+	if err := u.SelfUpdateWithLatestAndRestart(`C:\myapp.exe`); err != nil {
+		t.Fatalf("SelfUpdateWithLatestAndRestart() error = %v", err)
+	}
+	if len(before) != 1 || len(after) != 1 || before[0] != "v1.2.3" {
+		t.Errorf("hooks called with %v and %v, want v1.2.3 once", before, after)
+	}
+}
+
+func TestPreUpdateVeto(t *testing.T) {
+	errBusy := errors.New("job running")
+	tests := []struct {
+		name           string
+		hook           error
+		wantReason     string
+		wantRetryAfter time.Duration
+	}{
+		{name: "veto", hook: Veto("job running"), wantReason: "job running"},
+		{name: "postpone", hook: Postpone("queue not empty", time.Minute), wantReason: "queue not empty", wantRetryAfter: time.Minute},
+		{name: "plain error", hook: errBusy, wantReason: "job running"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before int
+			u, err := New(
+				WithRepository("owner/repo"),
+				WithVersion("v0.0.2"),
+				WithAssetFilter("^myapp-.*windows.*zip$"),
+				WithSource(&WebOperationsMock{}),
+				WithHooks(Hooks{
+					PreUpdate:    func(latest LatestRelease) error { return tt.hook },
+					BeforeUpdate: func(latest LatestRelease) { before++ },
+				}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u.fops = &FileOperationsMock{}
+			u.osps = &FailingOsOperationsMock{}
+
+			err = u.SelfUpdateWithLatestAndRestart(`C:\myapp.exe`)
+			var veto *VetoError
+			if !errors.As(err, &veto) {
+				t.Fatalf("SelfUpdateWithLatestAndRestart() error = %v, want *VetoError", err)
+			}
+			if veto.Reason != tt.wantReason || veto.RetryAfter != tt.wantRetryAfter {
+				t.Errorf("VetoError = %+v, want reason %q and retry after %v", veto, tt.wantReason, tt.wantRetryAfter)
+			}
+			if tt.hook == errBusy && !errors.Is(err, errBusy) {
+				t.Errorf("SelfUpdateWithLatestAndRestart() error = %v, want to wrap %v", err, errBusy)
+			}
+			if before != 0 {
+				t.Errorf("BeforeUpdate called after veto")
+			}
+		})
+	}
+}
+
+func TestWithNilLogger(t *testing.T) {
+	u, err := New(WithRepository("owner/repo"), WithVersion("v0.0.2"), WithLogger(nil))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	u.log.Info("must not panic")
+}
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	u, err := New(
+		WithRepository("owner/repo"),
+		WithVersion("v0.0.2"),
+		WithAssetFilter("^myapp-.*windows.*zip$"),
+		WithSource(&WebOperationsMock{}),
+		WithLogger(slog.New(slog.NewTextHandler(&buf, nil))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := u.GetLatestVersion(); err != nil {
+		t.Fatalf("GetLatestVersion() error = %v", err)
+	}
+	for _, want := range []string{"msg=\"asset chosen\"", "asset=myapp-v0.0.3-windows-amd64.zip", "repository=owner/repo"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log output %q does not contain %q", buf.String(), want)
+		}
+	}
+}
+
+func TestRollback(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "myapp.exe")
+	os.WriteFile(exe, []byte("new"), 0755)
+	os.WriteFile(exe+".old", []byte("old"), 0755)
+
+	u, err := New(WithRepository("owner/repo"), WithVersion("v0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := u.Rollback(exe); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if got, _ := os.ReadFile(exe); string(got) != "old" {
+		t.Errorf("Rollback() executable = %q, want old", got)
+	}
+
+	var swapErr *SwapError
+	if err := u.Rollback(exe); !errors.As(err, &swapErr) || swapErr.Stage != StageRollback {
+		t.Errorf("Rollback() without backup error = %v, want *SwapError", err)
+	}
+}
+
+func TestRestartOptions(t *testing.T) {
+	u, err := New(WithRepository("owner/repo"), WithVersion("v1.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the application changes its working directory after the start
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	opts := u.restartOptions("myapp")
+	if want := []string{internal.EnvPreviousVersion + "=v1.0.0"}; !reflect.DeepEqual(opts.Env, want) {
+		t.Errorf("restartOptions().Env = %v, want %v", opts.Env, want)
+	}
+	if !reflect.DeepEqual(opts.Args, os.Args[1:]) {
+		t.Errorf("restartOptions().Args = %v, want %v", opts.Args, os.Args[1:])
+	}
+	if opts.Dir != wd {
+		t.Errorf("restartOptions().Dir = %v, want the start directory %v", opts.Dir, wd)
+	}
+
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	u, _ = New(WithRepository("owner/repo"), WithVersion("v1.0.0"), WithRestartArgs(), WithRestartFiles(f))
+	opts = u.restartOptions("myapp")
+	if opts.Args == nil || len(opts.Args) != 0 {
+		t.Errorf("restartOptions().Args = %#v, want no arguments", opts.Args)
+	}
+	if len(opts.ExtraFiles) != 1 || opts.ExtraFiles[0] != f {
+		t.Errorf("restartOptions().ExtraFiles = %v", opts.ExtraFiles)
+	}
+}
@@ -0,0 +1,186 @@
+package update
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dhcgn/gh-update/internal"
+	"github.com/dhcgn/gh-update/types"
+)
+
+// DefaultSmokeTestTimeout is the timeout of the smoke test if WithSmokeTest is called without one.
+const DefaultSmokeTestTimeout = 10 * time.Second
+
+// WithSmokeTest executes the downloaded executable with args, e.g. "--version", before it replaces the running one.
+// The update is aborted with a *ValidationError if it does not exit with code 0 within timeout
+// or its output does not contain the version of the release.
+func WithSmokeTest(timeout time.Duration, args ...string) Option {
+	return func(u *Updater) {
+		if timeout <= 0 {
+			timeout = DefaultSmokeTestTimeout
+		}
+		u.smokeTest = true
+		u.smokeTimeout = timeout
+		u.smokeArgs = args
+	}
+}
+
+// WithExecutableCheck enables or disables the check that the downloaded executable is an ELF, PE or Mach-O
+// executable for the running GOOS and GOARCH, it is enabled by default.
+func WithExecutableCheck(enabled bool) Option {
+	return func(u *Updater) {
+		u.skipExecutableCheck = !enabled
+	}
+}
+
+// WithBuildInfoCheck enables or disables the check that the downloaded executable is built from the same
+// Go main module as the executable it replaces, it is enabled by default. A major version suffix like "/v2" is ignored.
+// The check is skipped if the replaced executable has no Go build information.
+func WithBuildInfoCheck(enabled bool) Option {
+	return func(u *Updater) {
+		u.skipBuildInfoCheck = !enabled
+	}
+}
+
+// validate checks the staged executable at path which replaces exepath before it is swapped in
+// and returns its build information, which is nil if it has none.
+func (u *Updater) validate(latest LatestRelease, exepath string, path string) (*types.BuildInfo, error) {
+	if !u.skipExecutableCheck {
+		if err := u.checkExecutable(path); err != nil {
+			return nil, &ValidationError{Check: CheckExecutable, Path: path, Err: err}
+		}
+	}
+
+	info, err := u.fops.BuildInfo(path)
+	var bi *types.BuildInfo
+	if err == nil {
+		bi = &info
+	}
+	if !u.skipBuildInfoCheck {
+		if err := u.checkBuildInfo(exepath, bi, err); err != nil {
+			return nil, &ValidationError{Check: CheckBuildInfo, Path: path, Err: err}
+		}
+	}
+
+	if u.smokeTest {
+		if err := u.runSmokeTest(latest, path); err != nil {
+			return nil, &ValidationError{Check: CheckSmokeTest, Path: path, Err: err}
+		}
+	}
+	return bi, nil
+}
+
+// checkBuildInfo compares the main module of the new executable with the one of exepath.
+func (u *Updater) checkBuildInfo(exepath string, bi *types.BuildInfo, readErr error) error {
+	current, err := u.fops.BuildInfo(exepath)
+	if err != nil || current.ModulePath == "" {
+		u.log.Debug("build info check skipped", "path", exepath, "error", err)
+		return nil
+	}
+	if readErr != nil {
+		return fmt.Errorf("no go build info: %w", readErr)
+	}
+	if modulePathWithoutMajor(bi.ModulePath) != modulePathWithoutMajor(current.ModulePath) {
+		return fmt.Errorf("main module %s differs from %s", bi.ModulePath, current.ModulePath)
+	}
+	return nil
+}
+
+// modulePathWithoutMajor returns path without a major version suffix like "/v2",
+// so a release of a new major version passes the build info check.
+func modulePathWithoutMajor(path string) string {
+	i := strings.LastIndex(path, "/v")
+	if i < 0 || i+2 == len(path) {
+		return path
+	}
+	for _, c := range path[i+2:] {
+		if c < '0' || c > '9' {
+			return path
+		}
+	}
+	return path[:i]
+}
+
+// checkExecutable checks that path is an executable for the running platform.
+func (u *Updater) checkExecutable(path string) error {
+	want := internal.ExecutableFormat(runtime.GOOS)
+	if want == "" {
+		return nil
+	}
+	info, err := u.fops.ExecutableInfo(path)
+	if err != nil {
+		return err
+	}
+	if info.Format != want {
+		return fmt.Errorf("%s executable does not run on %s", info.Format, runtime.GOOS)
+	}
+	if !slices.Contains(info.Archs, runtime.GOARCH) {
+		return fmt.Errorf("executable for %s does not run on %s", strings.Join(info.Archs, ", "), runtime.GOARCH)
+	}
+	return nil
+}
+
+func (u *Updater) runSmokeTest(latest LatestRelease, path string) error {
+	out, err := u.osps.Run(path, u.smokeArgs, u.smokeTimeout)
+	u.log.Debug("smoke test", "path", path, "args", u.smokeArgs, "output", string(out), "error", err)
+	if err != nil {
+		return err
+	}
+	if !containsVersion(out, latest.Version) {
+		return fmt.Errorf("output %q does not contain version %s", truncateOutput(out), latest.Version)
+	}
+	return nil
+}
+
+// containsVersion reports if out contains version with or without the "v" prefix as a whole word,
+// e.g. 1.2.3 is found in "myapp v1.2.3." but not in "11.2.3" or "1.2.30".
+func containsVersion(out []byte, version string) bool {
+	v := []byte(strings.TrimPrefix(version, "v"))
+	if len(v) == 0 {
+		return false
+	}
+	for i := 0; ; {
+		j := bytes.Index(out[i:], v)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(v)
+		if versionStart(out[:start]) && versionEnd(out[end:]) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+// versionStart reports if a version may start after before.
+func versionStart(before []byte) bool {
+	if n := len(before); n > 0 && (before[n-1] == 'v' || before[n-1] == 'V') {
+		before = before[:n-1]
+	}
+	return len(before) == 0 || !isVersionByte(before[len(before)-1]) && before[len(before)-1] != '.'
+}
+
+// versionEnd reports if a version may end before after, a trailing separator must not continue the version.
+func versionEnd(after []byte) bool {
+	if len(after) > 0 && (after[0] == '.' || after[0] == '-' || after[0] == '+') {
+		after = after[1:]
+	}
+	return len(after) == 0 || !isVersionByte(after[0])
+}
+
+func isVersionByte(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+func truncateOutput(out []byte) string {
+	const limit = 200
+	s := strings.TrimSpace(string(out))
+	if len(s) > limit {
+		return s[:limit] + "..."
+	}
+	return s
+}
@@ -0,0 +1,298 @@
+package update
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/dhcgn/gh-update/internal"
+	"github.com/dhcgn/gh-update/types"
+)
+
+type ExecutableFileOperationsMock struct {
+	FileOperationsMock
+	info internal.ExecutableInfo
+}
+
+// ExecutableInfo implements internal.FileOperations
+func (f *ExecutableFileOperationsMock) ExecutableInfo(path string) (internal.ExecutableInfo, error) {
+	return f.info, nil
+}
+
+type BuildInfoFileOperationsMock struct {
+	FileOperationsMock
+	infos map[string]types.BuildInfo
+}
+
+// BuildInfo implements internal.FileOperations
+func (f *BuildInfoFileOperationsMock) BuildInfo(path string) (types.BuildInfo, error) {
+	info, ok := f.infos[path]
+	if !ok {
+		return types.BuildInfo{}, errors.New("not a Go executable")
+	}
+	return info, nil
+}
+
+type SmokeOsOperationsMock struct {
+	OsOperationsMock
+	output []byte
+	err    error
+	args   []string
+}
+
+// Run implements internal.OsOperations
+func (o *SmokeOsOperationsMock) Run(path string, args []string, timeout time.Duration) ([]byte, error) {
+	o.args = args
+	return o.output, o.err
+}
+
+func TestSmokeTest(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		err     error
+		wantErr bool
+	}{
+		{name: "version printed", output: "myapp version 1.2.3\n"},
+		{name: "version with prefix", output: "myapp v1.2.3"},
+		{name: "wrong version", output: "myapp v0.0.2", wantErr: true},
+		{name: "longer version", output: "myapp v11.2.30", wantErr: true},
+		{name: "exit code", output: "<html>", err: errors.New("exit status 1"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before int
+			u, err := New(
+				WithRepository("owner/repo"),
+				WithVersion("v0.0.2"),
+				WithAssetFilter("^myapp-.*windows.*zip$"),
+				WithSource(&WebOperationsMock{}),
+				WithSmokeTest(time.Second, "--version"),
+				WithHooks(Hooks{BeforeUpdate: func(latest LatestRelease) { before++ }}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			osps := &SmokeOsOperationsMock{output: []byte(tt.output), err: tt.err}
+			u.fops = &FileOperationsMock{}
+			u.osps = osps
+
+			err = u.SelfUpdateWithLatestAndRestart(`C:\myapp.exe`)
+			if len(osps.args) != 1 || osps.args[0] != "--version" {
+				t.Errorf("smoke test args = %v, want [--version]", osps.args)
+			}
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("SelfUpdateWithLatestAndRestart() error = %v", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) || verr.Check != CheckSmokeTest {
+				t.Fatalf("SelfUpdateWithLatestAndRestart() error = %v, want *ValidationError", err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("SelfUpdateWithLatestAndRestart() error = %v, want to wrap %v", err, tt.err)
+			}
+			if before != 0 {
+				t.Errorf("executable replaced after failed smoke test")
+			}
+		})
+	}
+}
+
+func TestExecutableCheck(t *testing.T) {
+	if internal.ExecutableFormat(runtime.GOOS) == "" {
+		t.Skip("no executable format for", runtime.GOOS)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := filepath.Join(t.TempDir(), "index.html")
+	os.WriteFile(text, []byte("<html>Not Found</html>"), 0755)
+
+	otherArch := "arm64"
+	if runtime.GOARCH == otherArch {
+		otherArch = "amd64"
+	}
+	otherFormat := internal.FormatPE
+	if runtime.GOOS == "windows" {
+		otherFormat = internal.FormatELF
+	}
+
+	tests := []struct {
+		name    string
+		fops    internal.FileOperations
+		path    string
+		wantErr bool
+	}{
+		{name: "running executable", fops: internal.FileOperationsImpl{}, path: self},
+		{name: "html page", fops: internal.FileOperationsImpl{}, path: text, wantErr: true},
+		{name: "other architecture", fops: &ExecutableFileOperationsMock{info: internal.ExecutableInfo{Format: internal.ExecutableFormat(runtime.GOOS), Archs: []string{otherArch}}}, wantErr: true},
+		{name: "other os", fops: &ExecutableFileOperationsMock{info: internal.ExecutableInfo{Format: otherFormat, Archs: []string{runtime.GOARCH}}}, wantErr: true},
+		{name: "universal binary", fops: &ExecutableFileOperationsMock{info: internal.ExecutableInfo{Format: internal.ExecutableFormat(runtime.GOOS), Archs: []string{otherArch, runtime.GOARCH}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := New(WithRepository("owner/repo"), WithVersion("v0.0.2"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			u.fops = tt.fops
+
+			_, err = u.validate(LatestRelease{Version: "v1.2.3"}, "myapp", tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var verr *ValidationError
+			if tt.wantErr && (!errors.As(err, &verr) || verr.Check != CheckExecutable) {
+				t.Errorf("validate() error = %v, want *ValidationError", err)
+			}
+		})
+	}
+
+	u, _ := New(WithRepository("owner/repo"), WithVersion("v0.0.2"), WithExecutableCheck(false))
+	u.fops = internal.FileOperationsImpl{}
+	if _, err := u.validate(LatestRelease{Version: "v1.2.3"}, "myapp", text); err != nil {
+		t.Errorf("validate() with disabled check error = %v", err)
+	}
+}
+
+func TestBuildInfoCheck(t *testing.T) {
+	const exe = `C:\myapp.exe`
+	staged := exe + ".new.temp"
+	current := types.BuildInfo{Path: "example.com/myapp/cmd/myapp", ModulePath: "example.com/myapp", GoVersion: "go1.21.0"}
+	newer := types.BuildInfo{Path: "example.com/myapp/cmd/myapp", ModulePath: "example.com/myapp", GoVersion: "go1.22.1", Revision: "abc123"}
+	other := types.BuildInfo{Path: "example.com/other", ModulePath: "example.com/other", GoVersion: "go1.22.1"}
+	major := types.BuildInfo{Path: "example.com/myapp/v2/cmd/myapp", ModulePath: "example.com/myapp/v2", GoVersion: "go1.22.1"}
+	otherMajor := types.BuildInfo{Path: "example.com/myappv2", ModulePath: "example.com/myappv2", GoVersion: "go1.22.1"}
+
+	tests := []struct {
+		name     string
+		infos    map[string]types.BuildInfo
+		disabled bool
+		wantErr  bool
+		want     *types.BuildInfo
+	}{
+		{name: "same module", infos: map[string]types.BuildInfo{exe: current, staged: newer}, want: &newer},
+		{name: "other module", infos: map[string]types.BuildInfo{exe: current, staged: other}, wantErr: true},
+		{name: "new major version", infos: map[string]types.BuildInfo{exe: current, staged: major}, want: &major},
+		{name: "from major version", infos: map[string]types.BuildInfo{exe: major, staged: newer}, want: &newer},
+		{name: "module with version like suffix", infos: map[string]types.BuildInfo{exe: current, staged: otherMajor}, wantErr: true},
+		{name: "no build info", infos: map[string]types.BuildInfo{exe: current}, wantErr: true},
+		{name: "current without build info", infos: map[string]types.BuildInfo{staged: other}, want: &other},
+		{name: "disabled", infos: map[string]types.BuildInfo{exe: current, staged: other}, disabled: true, want: &other},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := New(
+				WithRepository("owner/repo"),
+				WithVersion("v0.0.2"),
+				WithAssetFilter("^myapp-.*windows.*zip$"),
+				WithSource(&WebOperationsMock{}),
+				WithBuildInfoCheck(!tt.disabled),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u.fops = &BuildInfoFileOperationsMock{infos: tt.infos}
+
+			latest, err := u.GetLatestVersion()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := u.ApplyWithResult(latest, exe)
+			if tt.wantErr {
+				var verr *ValidationError
+				if !errors.As(err, &verr) || verr.Check != CheckBuildInfo {
+					t.Fatalf("ApplyWithResult() error = %v, want *ValidationError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyWithResult() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.BuildInfo, tt.want) {
+				t.Errorf("ApplyWithResult().BuildInfo = %+v, want %+v", got.BuildInfo, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadBuildInfo(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := internal.FileOperationsImpl{}.BuildInfo(self)
+	if err != nil {
+		t.Fatalf("BuildInfo() error = %v", err)
+	}
+	if info.ModulePath != "github.com/dhcgn/gh-update" || info.GoVersion != runtime.Version() {
+		t.Errorf("BuildInfo() = %+v", info)
+	}
+}
+
+func TestContainsVersion(t *testing.T) {
+	tests := []struct {
+		out  string
+		want bool
+	}{
+		{out: "1.2.3", want: true},
+		{out: "myapp v1.2.3\n", want: true},
+		{out: "myapp version 1.2.3.", want: true},
+		{out: "myapp-1.2.3 (go1.21)", want: true},
+		{out: "myapp 11.2.3", want: false},
+		{out: "myapp 1.2.30", want: false},
+		{out: "myapp 0.1.2.3", want: false},
+		{out: "myapp 1.2.3.4", want: false},
+		{out: "myapp 1.2.3-rc.1", want: false},
+		{out: "myapp 11.2.3, 1.2.3", want: true},
+		{out: "dev1.2.3", want: false},
+	}
+	for _, tt := range tests {
+		if got := containsVersion([]byte(tt.out), "v1.2.3"); got != tt.want {
+			t.Errorf("containsVersion(%q) = %v, want %v", tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestRunWithBackgroundChild(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no shell scripts on windows")
+	}
+
+	tests := []struct {
+		name    string
+		script  string
+		wantErr bool
+	}{
+		{name: "exits", script: "sleep 30 &\necho myapp 1.2.3\n"},
+		{name: "hangs", script: "sleep 30 &\necho myapp 1.2.3\nsleep 30\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script := filepath.Join(t.TempDir(), "myapp")
+			if err := os.WriteFile(script, []byte("#!/bin/sh\n"+tt.script), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			out, err := internal.OsOperationsImpl{}.Run(script, nil, 2*time.Second)
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("Run() took %v, want to stop after the timeout", elapsed)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !containsVersion(out, "v1.2.3") {
+				t.Errorf("Run() output = %q, want the version", out)
+			}
+		})
+	}
+}
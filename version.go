@@ -0,0 +1,82 @@
+package update
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareVersions compares two semantic versions like "v1.2.3" or "1.3.0-rc.1"
+// and returns -1 if a < b, 0 if a == b and +1 if a > b.
+// The "v" prefix and build metadata are ignored, missing parts count as zero
+// and a pre-release is lower than the release.
+func CompareVersions(a, b string) int {
+	aCore, aPre := splitVersion(a)
+	bCore, bPre := splitVersion(b)
+
+	for i := 0; i < len(aCore) || i < len(bCore); i++ {
+		if c := compareIdentifier(partAt(aCore, i), partAt(bCore, i)); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case aPre == "" && bPre == "":
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+
+	aIds, bIds := strings.Split(aPre, "."), strings.Split(bPre, ".")
+	for i := 0; i < len(aIds) && i < len(bIds); i++ {
+		if c := compareIdentifier(aIds[i], bIds[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(aIds), len(bIds))
+}
+
+func splitVersion(v string) (core []string, pre string) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		v, pre = v[:i], v[i+1:]
+	}
+	return strings.Split(v, "."), pre
+}
+
+func partAt(parts []string, i int) string {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return "0"
+}
+
+// compareIdentifier compares numeric identifiers numerically and others lexically,
+// numeric identifiers are lower than alphanumeric ones.
+func compareIdentifier(a, b string) int {
+	ai, aErr := strconv.Atoi(a)
+	bi, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareInt(ai, bi)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}